@@ -6,18 +6,60 @@ import (
 	"go9cc/token"
 	"go9cc/types"
 	"os"
+	"strconv"
 	"strings"
 )
 
 type LocalVariable struct {
-	Name    string
-	Type    types.Type
-	IsLocal bool
-	offset  int
+	Name     string
+	Type     types.Type
+	IsLocal  bool
+	IsStatic bool
+	token    *token.Token
+
+	// IsEnum marks an enumeration constant. Its value is Val and it has
+	// no storage.
+	IsEnum bool
+	Val    int
+
+	// IsTypedef marks a typedef name of Type.
+	IsTypedef bool
+
+	// Offset is the end of the stack slot of a local from the bottom of
+	// the frame. Variables in disjoint scopes may share their slots.
+	Offset int
+}
+
+func NewLocalVariable(name string, typ types.Type, isLocal bool, token *token.Token) *LocalVariable {
+	return &LocalVariable{Name: name, Type: typ, IsLocal: isLocal, token: token}
+}
+
+// NewEnumConstant returns the enumeration constant name of value val.
+func NewEnumConstant(name string, val int, token *token.Token) *LocalVariable {
+	return &LocalVariable{Name: name, Type: types.GetInt(), Val: val, IsEnum: true, token: token}
+}
+
+// NewTypedef returns the typedef name name of typ.
+func NewTypedef(name string, typ types.Type, token *token.Token) *LocalVariable {
+	return &LocalVariable{Name: name, Type: typ, IsTypedef: true, token: token}
+}
+
+func (n *LocalVariable) Token() *token.Token {
+	return n.token
 }
 
 func (n *LocalVariable) String() string {
-	return n.Type.String() + " " + n.Name
+	s := n.Type.String() + " " + n.Name
+	if n.IsEnum {
+		s += " = " + strconv.Itoa(n.Val)
+	}
+	if n.IsStatic {
+		s = "static " + s
+	}
+	if n.IsTypedef {
+		s = "typedef " + s
+	}
+	return s
 }
 
 type Node interface {
@@ -37,13 +79,18 @@ type Exp interface {
 }
 
 type TypeError struct {
-	msg string
+	msg   string
+	token *token.Token
 }
 
 func (t *TypeError) Error() string {
 	return t.msg
 }
 
+func (t *TypeError) Token() *token.Token {
+	return t.token
+}
+
 /* Stmt List Stmt */
 
 type StmtListNode struct {
@@ -143,7 +190,7 @@ func (n *StringLiteralExp) Token() *token.Token {
 }
 
 func (n *StringLiteralExp) String() string {
-	return fmt.Sprintf("\"%s\"", n.Val)
+	return strconv.Quote(n.Val)
 }
 
 func (n *StringLiteralExp) Type() types.Type {
@@ -159,11 +206,18 @@ func (n *StringLiteralExp) Length() int {
 type NumExp struct {
 	Val   int
 	token *token.Token
+	typ   types.Type
 }
 
 func NewNumExp(val int, token *token.Token) *NumExp {
+	return NewTypedNumExp(val, token, types.GetInt())
+}
+
+// NewTypedNumExp returns a literal of typ, e.g. unsigned long for 1ul.
+// val is converted to typ.
+func NewTypedNumExp(val int, token *token.Token, typ types.Type) *NumExp {
 	return &NumExp{
-		Val: val, token: token,
+		Val: types.Convert(val, typ), token: token, typ: typ,
 	}
 }
 
@@ -178,7 +232,7 @@ func (n *NumExp) String() string {
 }
 
 func (n *NumExp) Type() types.Type {
-	return types.GetInt()
+	return n.typ
 }
 
 /* Infix */
@@ -212,14 +266,59 @@ func (n *InfixExp) String() string {
 }
 
 func (n *InfixExp) Type() types.Type {
+	switch n.Op {
+	case "==", "!=", "<", "<=", ">", ">=", "&&", "||":
+		return types.GetInt()
+	case "<<", ">>":
+		return types.Promote(n.Left.Type())
+	case "+", "-", "*", "/", "%", "&", "|", "^":
+		l, r := n.Left.Type(), n.Right.Type()
+		if types.IsInteger(l) && types.IsInteger(r) {
+			return types.Common(l, r)
+		}
+	}
 	return n.Left.Type()
 }
 
+// BinaryOp returns the operator of a compound assignment without "=",
+// e.g. "+" for "+=". It returns "" for other operators.
+func (n *InfixExp) BinaryOp() string {
+	switch n.Op {
+	case "+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "<<=", ">>=":
+		return strings.TrimSuffix(n.Op, "=")
+	}
+	return ""
+}
+
 func (n *InfixExp) CheckTypeError() error {
-	ret := &TypeError{}
+	if err := CheckVoid(n.Left); err != nil {
+		return err
+	}
+	if err := CheckVoid(n.Right); err != nil {
+		return err
+	}
+
+	ret := &TypeError{token: n.token}
+	switch n.Op {
+	case "=", "+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "<<=", ">>=":
+		if !IsLvalue(n.Left) {
+			ret.msg = "lvalue required as left operand of assignment"
+			return ret
+		}
+	}
+
 	switch n.Op {
+	case "+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "<<=", ">>=":
+		if !IsModifiable(n.Left) {
+			ret.msg = fmt.Sprintf("Cannot assign to %s", n.Left)
+			return ret
+		}
+
+		// a += b is checked as a + b
+		op := NewInfixExp(n.Left, n.Right, n.BinaryOp(), n.token)
+		return op.CheckTypeError()
 	case "=":
-		if !n.Left.Type().CanAssign(n.Right.Type()) {
+		if !CanAssign(n.Left.Type(), n.Right) {
 			ret.msg = fmt.Sprintf("Cannot assign: %s", n)
 			return ret
 		}
@@ -237,6 +336,27 @@ func (n *InfixExp) CheckTypeError() error {
 			ret.msg = fmt.Sprintf("Cannot add/sub: %s", n)
 			return ret
 		}
+	case "%":
+		if !n.Left.Type().CanMod(n.Right.Type()) {
+			ret.msg = fmt.Sprintf("Cannot mod: %s", n)
+			return ret
+		}
+	case "&":
+		fallthrough
+	case "|":
+		fallthrough
+	case "^":
+		if !n.Left.Type().CanBitwise(n.Right.Type()) {
+			ret.msg = fmt.Sprintf("Cannot and/or/xor: %s", n)
+			return ret
+		}
+	case "<<":
+		fallthrough
+	case ">>":
+		if !n.Left.Type().CanShift(n.Right.Type()) {
+			ret.msg = fmt.Sprintf("Cannot shift: %s", n)
+			return ret
+		}
 	}
 
 	return nil
@@ -272,19 +392,90 @@ func (n *DeclarationStmt) String() string {
 	return out.String()
 }
 
+// Target returns the variable initialized by Exp. In int a, b = 1;
+// the initializer belongs to the last one.
+func (n *DeclarationStmt) Target() *LocalVariable {
+	return n.LV.Locals[len(n.LV.Locals)-1]
+}
+
 func (n *DeclarationStmt) CheckTypeError() error {
 	if n.Exp == nil {
 		return nil
 	}
 
-	ret := &TypeError{}
-	for _, local := range n.LV.Locals {
-		if !local.Type.CanAssign(n.Exp.Type()) {
+	if err := CheckVoid(n.Exp); err != nil {
+		return err
+	}
+
+	local := n.Target()
+	ret := &TypeError{token: n.token}
+	switch exp := n.Exp.(type) {
+	case *StringLiteralExp:
+		// char s[3] = "abc"; drops the terminating null like C.
+		arr, ok := local.Type.(*types.Array)
+		if ok && types.IsChar(arr.Base) {
+			if len(exp.Val) > arr.Length {
+				ret.msg = fmt.Sprintf("Initializer string is too long for %s", local)
+				return ret
+			}
+			return nil
+		}
+	case *ArrayLiteral:
+		if st, ok := local.Type.(*types.Struct); ok {
+			return checkStructLiteral(st, exp, local)
+		}
+
+		arr, ok := local.Type.(*types.Array)
+		if !ok {
 			ret.msg = fmt.Sprintf("Type mismatch: %s", local)
 			return ret
 		}
+
+		if len(exp.Exps) > arr.Length {
+			ret.msg = fmt.Sprintf("Excess elements in array initializer of %s", local)
+			return ret
+		}
+
+		for _, elem := range exp.Exps {
+			if !CanAssign(arr.Base, elem) {
+				ret.token = elem.Token()
+				ret.msg = fmt.Sprintf("Type mismatch: %s for element of %s", elem.Type(), local)
+				return ret
+			}
+		}
+		return nil
+	}
+
+	if !CanAssign(local.Type, n.Exp) {
+		ret.msg = fmt.Sprintf("Type mismatch: %s", local)
+		return ret
+	}
+
+	return nil
+}
+
+// checkStructLiteral checks that {...} has an element assignable to each
+// member of st from the first. A union takes only one.
+func checkStructLiteral(st *types.Struct, exp *ArrayLiteral, local *LocalVariable) error {
+	// A union is initialized through its first member.
+	max := len(st.Members)
+	if st.IsUnion && max > 1 {
+		max = 1
 	}
 
+	if len(exp.Exps) > max {
+		return &TypeError{msg: fmt.Sprintf("Excess elements in %s initializer of %s", st.Kind(), local), token: exp.token}
+	}
+
+	for i, elem := range exp.Exps {
+		member := st.Members[i]
+		if !CanAssign(member.Type, elem) {
+			return &TypeError{
+				msg:   fmt.Sprintf("Type mismatch: %s for member %s of %s", elem.Type(), member.Name, local),
+				token: elem.Token(),
+			}
+		}
+	}
 	return nil
 }
 
@@ -325,23 +516,464 @@ func (n *UnaryExp) Type() types.Type {
 		fallthrough
 	case "-":
 		fallthrough
+	case "~":
+		return types.Promote(n.Right.Type())
 	case "*":
+		switch ty := n.Right.Type().(type) {
+		case *types.IntPointer:
+			return ty.Base
+		case *types.Array:
+			return ty.Base
+		}
 		return types.GetInt()
 	case "&":
 		return types.PointerTo(n.Right.Type())
 	case "sizeof":
 		return types.GetInt()
+	case "!":
+		return types.GetInt()
+	case "++":
+		fallthrough
+	case "--":
+		return n.Right.Type()
+	}
+
+	// Only reachable by a parser bug. Type() cannot return an error, so
+	// abort with one; parser and generator recover it.
+	panic(&TypeError{msg: fmt.Sprintf("Invalid op: %s", n.Op), token: n.token})
+}
+
+func (n *UnaryExp) CheckTypeError() error {
+	if n.Op != "sizeof" {
+		if err := CheckVoid(n.Right); err != nil {
+			return err
+		}
+	}
+
+	if n.Op == "sizeof" && !types.IsComplete(n.Right.Type()) {
+		return &TypeError{msg: fmt.Sprintf("Invalid application of sizeof to incomplete type %s", n.Right.Type()), token: n.token}
+	}
+
+	if n.Op == "&" && !IsLvalue(n.Right) {
+		return &TypeError{msg: "lvalue required as unary '&' operand", token: n.token}
+	}
+
+	if (n.Op == "-" || n.Op == "+") && !types.IsInteger(n.Right.Type()) {
+		return &TypeError{msg: fmt.Sprintf("Cannot apply unary %s: %s", n.Op, n), token: n.token}
+	}
+
+	if n.Op == "~" && !types.IsInteger(n.Right.Type()) {
+		return &TypeError{msg: fmt.Sprintf("Cannot complement: %s", n), token: n.token}
+	}
+
+	if n.Op == "++" || n.Op == "--" {
+		return checkIncDec(n.Right, n.token)
+	}
+
+	return nil
+}
+
+// CheckVoid reports an error if the value of exp is used but it has none.
+func CheckVoid(exp Exp) error {
+	if types.IsVoid(exp.Type()) {
+		return &TypeError{msg: "Void value not ignored as it ought to be", token: exp.Token()}
+	}
+	return nil
+}
+
+// checkIncDec checks the operand of ++ and --.
+func checkIncDec(exp Exp, token *token.Token) error {
+	if !IsLvalue(exp) {
+		operand := "increment"
+		if token.Str == "--" {
+			operand = "decrement"
+		}
+		return &TypeError{msg: fmt.Sprintf("lvalue required as %s operand", operand), token: token}
+	}
+
+	if !IsModifiable(exp) {
+		return &TypeError{msg: fmt.Sprintf("Cannot increment/decrement: %s", exp), token: token}
+	}
+
+	if !exp.Type().CanAdd(types.GetInt()) {
+		return &TypeError{msg: fmt.Sprintf("Cannot increment/decrement: %s", exp), token: token}
+	}
+
+	return nil
+}
+
+// IsModifiable reports whether exp is an lvalue that can be assigned.
+// An array is not, although it has an address.
+func IsModifiable(exp Exp) bool {
+	if _, ok := exp.Type().(*types.Array); ok {
+		return false
+	}
+	return IsLvalue(exp)
+}
+
+// IsLvalue reports whether exp designates an object, which has an
+// address.
+func IsLvalue(exp Exp) bool {
+	switch exp := exp.(type) {
+	case *IdentExp, *IndexExp, *MemberExp:
+		return true
+	case *UnaryExp:
+		return exp.Op == "*"
+	}
+	return false
+}
+
+/* Constant Expression */
+
+// Eval reduces a constant expression to a NumExp. The error is a
+// *TypeError at the part which is not constant.
+func Eval(exp Exp) (*NumExp, error) {
+	switch exp := exp.(type) {
+	case *NumExp:
+		return exp, nil
+	case *UnaryExp:
+		if exp.Op == "sizeof" {
+			return NewNumExp(exp.Right.Type().StackSize(), exp.Token()), nil
+		}
+
+		r, err := Eval(exp.Right)
+		if err != nil {
+			return nil, err
+		}
+
+		if exp.Op == "+" {
+			return r, nil
+		}
+
+		if exp.Op == "-" {
+			return NewTypedNumExp(-r.Val, exp.Token(), exp.Type()), nil
+		}
+
+		if exp.Op == "~" {
+			return NewTypedNumExp(^r.Val, exp.Token(), exp.Type()), nil
+		}
+
+		if exp.Op == "!" {
+			return NewTypedNumExp(boolToInt(r.Val == 0), exp.Token(), exp.Type()), nil
+		}
+
+		return nil, &TypeError{msg: fmt.Sprintf("Invalid operator for global rvalue unary right: %s", exp.Op), token: exp.Token()}
+	case *InfixExp:
+		l, err := Eval(exp.Left)
+		if err != nil {
+			return nil, err
+		}
+
+		// The right side is not evaluated if the left decides the result.
+		if exp.Op == "&&" || exp.Op == "||" {
+			if (l.Val != 0) == (exp.Op == "||") {
+				return NewTypedNumExp(boolToInt(l.Val != 0), exp.Token(), exp.Type()), nil
+			}
+
+			r, err := Eval(exp.Right)
+			if err != nil {
+				return nil, err
+			}
+			return NewTypedNumExp(boolToInt(r.Val != 0), exp.Token(), exp.Type()), nil
+		}
+
+		r, err := Eval(exp.Right)
+		if err != nil {
+			return nil, err
+		}
+
+		// Comparisons are done in the common type of both sides and make int.
+		switch exp.Op {
+		case "==", "!=", "<", "<=", ">", ">=":
+			val := compare(exp.Op, l, r)
+			return NewTypedNumExp(boolToInt(val), exp.Token(), exp.Type()), nil
+		}
+
+		// Both sides are converted to the type of the result like at run time.
+		ty := exp.Type()
+		lv, rv := types.Convert(l.Val, ty), types.Convert(r.Val, ty)
+		unsigned := types.IsUnsigned(ty)
+
+		if exp.Op == "+" {
+			val := lv + rv
+			return NewTypedNumExp(val, exp.Token(), ty), nil
+		}
+
+		if exp.Op == "-" {
+			val := lv - rv
+			return NewTypedNumExp(val, exp.Token(), ty), nil
+		}
+
+		if exp.Op == "*" {
+			val := lv * rv
+			return NewTypedNumExp(val, exp.Token(), ty), nil
+		}
+
+		if exp.Op == "/" {
+			if rv == 0 {
+				return nil, &TypeError{msg: fmt.Sprintf("Division by zero in global rvalue: %s", exp), token: exp.Token()}
+			}
+			val := lv / rv
+			if unsigned {
+				val = int(uint64(lv) / uint64(rv))
+			}
+			return NewTypedNumExp(val, exp.Token(), ty), nil
+		}
+
+		if exp.Op == "%" {
+			if rv == 0 {
+				return nil, &TypeError{msg: fmt.Sprintf("Division by zero in global rvalue: %s", exp), token: exp.Token()}
+			}
+			val := lv % rv
+			if unsigned {
+				val = int(uint64(lv) % uint64(rv))
+			}
+			return NewTypedNumExp(val, exp.Token(), ty), nil
+		}
+
+		if exp.Op == "&" {
+			val := lv & rv
+			return NewTypedNumExp(val, exp.Token(), ty), nil
+		}
+
+		if exp.Op == "|" {
+			val := lv | rv
+			return NewTypedNumExp(val, exp.Token(), ty), nil
+		}
+
+		if exp.Op == "^" {
+			val := lv ^ rv
+			return NewTypedNumExp(val, exp.Token(), ty), nil
+		}
+
+		if exp.Op == "<<" {
+			val := lv << uint(rv)
+			return NewTypedNumExp(val, exp.Token(), ty), nil
+		}
+
+		if exp.Op == ">>" {
+			val := lv >> uint(rv)
+			if unsigned {
+				val = int(uint64(lv) >> uint(rv))
+			}
+			return NewTypedNumExp(val, exp.Token(), ty), nil
+		}
+
+		return nil, &TypeError{msg: fmt.Sprintf("Invalid operator for global rvalue: %s", exp.Op), token: exp.Token()}
+	case *CondExp:
+		cond, err := Eval(exp.Cond)
+		if err != nil {
+			return nil, err
+		}
+
+		branch := exp.Else
+		if cond.Val != 0 {
+			branch = exp.Then
+		}
+
+		num, err := Eval(branch)
+		if err != nil {
+			return nil, err
+		}
+		return NewTypedNumExp(num.Val, exp.Token(), exp.Type()), nil
+	}
+
+	return nil, &TypeError{msg: fmt.Sprintf("Invalid exp for global rvalue: %s", exp), token: exp.Token()}
+}
+
+// compare returns l op r after converting both to their common type.
+func compare(op string, l, r *NumExp) bool {
+	ty := types.Common(l.Type(), r.Type())
+	lv, rv := types.Convert(l.Val, ty), types.Convert(r.Val, ty)
+	switch op {
+	case "==":
+		return lv == rv
+	case "!=":
+		return lv != rv
+	}
+
+	less, equal := lv < rv, lv == rv
+	if types.IsUnsigned(ty) {
+		less = uint64(lv) < uint64(rv)
+	}
+	switch op {
+	case "<":
+		return less
+	case "<=":
+		return less || equal
+	case ">":
+		return !less && !equal
+	}
+	return !less
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+/* Conditional */
+
+// CondExp is cond ? then : els.
+type CondExp struct {
+	Cond, Then, Else Exp
+	token            *token.Token
+}
+
+func NewCondExp(cond, then, els Exp, token *token.Token) *CondExp {
+	return &CondExp{Cond: cond, Then: then, Else: els, token: token}
+}
+
+func (n *CondExp) expNode() {}
+
+func (n *CondExp) Token() *token.Token {
+	return n.token
+}
+
+func (n *CondExp) String() string {
+	return fmt.Sprintf("(%s ? %s : %s)", n.Cond, n.Then, n.Else)
+}
+
+// Type is the common type of the arithmetic branches, or the pointer
+// type if a branch is a pointer and the other a pointer or 0. void*
+// wins over the other pointers. Two void branches make void.
+func (n *CondExp) Type() types.Type {
+	then, els := n.Then.Type(), n.Else.Type()
+	if types.IsInteger(then) && types.IsInteger(els) {
+		return types.Common(then, els)
+	}
+
+	if isPointer(then) && !isVoidPointer(els) {
+		return decay(then)
+	}
+	return decay(els)
+}
+
+func (n *CondExp) CheckTypeError() error {
+	if err := CheckVoid(n.Cond); err != nil {
+		return err
 	}
 
-	err("Invalid op: %s", n.Op)
-	os.Exit(1)
-	return types.GetInt()
+	then, els := n.Then.Type(), n.Else.Type()
+	switch {
+	case types.IsInteger(then) && types.IsInteger(els):
+		return nil
+	case types.IsVoid(then) && types.IsVoid(els):
+		return nil
+	case isPointer(then) && isPointer(els):
+		return nil
+	case isPointer(then) && IsNullPointer(n.Else):
+		return nil
+	case isPointer(els) && IsNullPointer(n.Then):
+		return nil
+	}
+
+	return &TypeError{
+		msg:   fmt.Sprintf("Type mismatch in conditional expression: %s and %s", then, els),
+		token: n.token,
+	}
+}
+
+// CanAssign reports whether exp can be assigned to ty. The null pointer
+// constant 0 converts to any pointer.
+func CanAssign(ty types.Type, exp Exp) bool {
+	if _, ok := ty.(*types.IntPointer); ok && IsNullPointer(exp) {
+		return true
+	}
+	return ty.CanAssign(exp.Type())
+}
+
+// IsNullPointer reports whether exp is the null pointer constant 0.
+func IsNullPointer(exp Exp) bool {
+	num, ok := exp.(*NumExp)
+	return ok && num.Val == 0
+}
+
+func isPointer(ty types.Type) bool {
+	switch ty.(type) {
+	case *types.IntPointer, *types.Array:
+		return true
+	}
+	return false
+}
+
+func isVoidPointer(ty types.Type) bool {
+	ptr, ok := ty.(*types.IntPointer)
+	return ok && types.IsVoid(ptr.Base)
+}
+
+// decay turns an array type into the pointer to its first element.
+func decay(ty types.Type) types.Type {
+	if arr, ok := ty.(*types.Array); ok {
+		return types.PointerTo(arr.Base)
+	}
+	return ty
+}
+
+/* Comma */
+
+// CommaExp evaluates Left and then Right, the value of the expression.
+type CommaExp struct {
+	Left, Right Exp
+	token       *token.Token
+}
+
+func NewCommaExp(left, right Exp, token *token.Token) *CommaExp {
+	return &CommaExp{Left: left, Right: right, token: token}
+}
+
+func (n *CommaExp) expNode() {}
+
+func (n *CommaExp) Token() *token.Token {
+	return n.token
+}
+
+func (n *CommaExp) String() string {
+	return fmt.Sprintf("(%s, %s)", n.Left, n.Right)
+}
+
+func (n *CommaExp) Type() types.Type {
+	return n.Right.Type()
+}
+
+/* Postfix */
+
+// PostfixExp is x++ or x--.
+type PostfixExp struct {
+	Left  Exp
+	Op    string
+	token *token.Token
+}
+
+func NewPostfixExp(left Exp, op string, token *token.Token) *PostfixExp {
+	return &PostfixExp{Left: left, Op: op, token: token}
+}
+
+func (n *PostfixExp) expNode() {}
+
+func (n *PostfixExp) Token() *token.Token {
+	return n.token
+}
+
+func (n *PostfixExp) String() string {
+	return "(" + n.Left.String() + n.Op + ")"
+}
+
+func (n *PostfixExp) Type() types.Type {
+	return n.Left.Type()
+}
+
+func (n *PostfixExp) CheckTypeError() error {
+	return checkIncDec(n.Left, n.token)
 }
 
 /* Identifier */
 
 type IdentExp struct {
 	Name  string
+	Var   *LocalVariable // the variable Name refers to in its scope
 	token *token.Token
 	typ   types.Type
 }
@@ -405,6 +1037,39 @@ func (n *IndexExp) Type() types.Type {
 	return arrayTyp.Base
 }
 
+/* Member Access */
+
+// MemberExp is s.name, or p->name which is (*p).name.
+type MemberExp struct {
+	Left   Exp    // a struct for ".", a pointer to a struct for "->"
+	Op     string // "." or "->"
+	Member *types.Member
+	token  *token.Token
+}
+
+func NewMemberExp(left Exp, op string, member *types.Member, token *token.Token) *MemberExp {
+	return &MemberExp{
+		Left:   left,
+		Op:     op,
+		Member: member,
+		token:  token,
+	}
+}
+
+func (n *MemberExp) expNode() {}
+
+func (n *MemberExp) Token() *token.Token {
+	return n.token
+}
+
+func (n *MemberExp) String() string {
+	return n.Left.String() + n.Op + n.Member.Name
+}
+
+func (n *MemberExp) Type() types.Type {
+	return n.Member.Type
+}
+
 /* Func Call Params */
 
 type FuncCallParams struct {
@@ -469,6 +1134,11 @@ func (n *FuncCallExp) Token() *token.Token {
 }
 
 func (n *FuncCallExp) Type() types.Type {
+	if n.Def == nil {
+		// Called without a declaration: implicitly declared as
+		// returning int.
+		return types.GetInt()
+	}
 	return n.Def.Type
 }
 
@@ -502,7 +1172,7 @@ func (n *ExpStmt) String() string {
 /* Return Statement */
 
 type ReturnStmt struct {
-	Exp   Exp
+	Exp   Exp // nil for return;
 	token *token.Token
 }
 
@@ -520,6 +1190,10 @@ func (n *ReturnStmt) Token() *token.Token {
 }
 
 func (n *ReturnStmt) String() string {
+	if n.Exp == nil {
+		return "return;"
+	}
+
 	var out bytes.Buffer
 	out.WriteString("return ")
 	out.WriteString(n.Exp.String())
@@ -595,6 +1269,38 @@ func (n *WhileStmt) String() string {
 	return out.String()
 }
 
+/* Do While Statement */
+
+type DoWhileStmt struct {
+	Body  Stmt
+	Cond  Exp
+	token *token.Token
+}
+
+func NewDoWhileStmt(body Stmt, cond Exp, token *token.Token) *DoWhileStmt {
+	return &DoWhileStmt{
+		Body:  body,
+		Cond:  cond,
+		token: token,
+	}
+}
+
+func (n *DoWhileStmt) stmtNode() {}
+
+func (n *DoWhileStmt) Token() *token.Token {
+	return n.token
+}
+
+func (n *DoWhileStmt) String() string {
+	var out bytes.Buffer
+	out.WriteString("do ")
+	out.WriteString(n.Body.String())
+	out.WriteString(" while (")
+	out.WriteString(n.Cond.String())
+	out.WriteString(");")
+	return out.String()
+}
+
 /* For Statement */
 
 type ForStmt struct {
@@ -633,6 +1339,151 @@ func (n *ForStmt) String() string {
 	return out.String()
 }
 
+/* Switch Statement */
+
+type SwitchStmt struct {
+	Cond    Exp
+	Body    Stmt
+	Cases   []*CaseStmt // without default, in source order
+	Default *CaseStmt
+	token   *token.Token
+}
+
+func NewSwitchStmt(cond Exp, token *token.Token) *SwitchStmt {
+	return &SwitchStmt{Cond: cond, Cases: []*CaseStmt{}, token: token}
+}
+
+func (n *SwitchStmt) stmtNode() {}
+
+func (n *SwitchStmt) Token() *token.Token {
+	return n.token
+}
+
+func (n *SwitchStmt) String() string {
+	var out bytes.Buffer
+	out.WriteString("switch (")
+	out.WriteString(n.Cond.String())
+	out.WriteString(") ")
+	out.WriteString(n.Body.String())
+	return out.String()
+}
+
+func (n *SwitchStmt) CheckTypeError() error {
+	if !types.IsInteger(n.Cond.Type()) {
+		return &TypeError{
+			msg:   fmt.Sprintf("Switch quantity is not an integer: %s", n.Cond),
+			token: n.Cond.Token(),
+		}
+	}
+	return nil
+}
+
+// CaseStmt is a case or default label of a switch and the statement
+// following it. Exp is nil for default.
+type CaseStmt struct {
+	Exp   Exp
+	Stmt  Stmt
+	token *token.Token
+}
+
+func NewCaseStmt(exp Exp, stmt Stmt, token *token.Token) *CaseStmt {
+	return &CaseStmt{Exp: exp, Stmt: stmt, token: token}
+}
+
+func (n *CaseStmt) stmtNode() {}
+
+func (n *CaseStmt) Token() *token.Token {
+	return n.token
+}
+
+func (n *CaseStmt) String() string {
+	if n.Exp == nil {
+		return fmt.Sprintf("default: %s", n.Stmt)
+	}
+	return fmt.Sprintf("case %s: %s", n.Exp, n.Stmt)
+}
+
+/* Jump Statement */
+
+// BreakStmt leaves the innermost loop or switch.
+type BreakStmt struct {
+	token *token.Token
+}
+
+func NewBreakStmt(token *token.Token) *BreakStmt {
+	return &BreakStmt{token: token}
+}
+
+func (n *BreakStmt) stmtNode() {}
+
+func (n *BreakStmt) Token() *token.Token {
+	return n.token
+}
+
+func (n *BreakStmt) String() string {
+	return "break;"
+}
+
+// ContinueStmt goes to the next iteration of the innermost loop.
+type ContinueStmt struct {
+	token *token.Token
+}
+
+func NewContinueStmt(token *token.Token) *ContinueStmt {
+	return &ContinueStmt{token: token}
+}
+
+func (n *ContinueStmt) stmtNode() {}
+
+func (n *ContinueStmt) Token() *token.Token {
+	return n.token
+}
+
+func (n *ContinueStmt) String() string {
+	return "continue;"
+}
+
+type GotoStmt struct {
+	Label string
+	token *token.Token
+}
+
+func NewGotoStmt(label string, token *token.Token) *GotoStmt {
+	return &GotoStmt{Label: label, token: token}
+}
+
+func (n *GotoStmt) stmtNode() {}
+
+func (n *GotoStmt) Token() *token.Token {
+	return n.token
+}
+
+func (n *GotoStmt) String() string {
+	return fmt.Sprintf("goto %s;", n.Label)
+}
+
+/* Labeled Statement */
+
+type LabeledStmt struct {
+	Label string
+	Stmt  Stmt
+	token *token.Token
+}
+
+func NewLabeledStmt(label string, stmt Stmt, token *token.Token) *LabeledStmt {
+	return &LabeledStmt{Label: label, Stmt: stmt, token: token}
+}
+
+func (n *LabeledStmt) stmtNode() {}
+
+func (n *LabeledStmt) Token() *token.Token {
+	return n.token
+}
+
+func (n *LabeledStmt) String() string {
+	return fmt.Sprintf("%s: %s", n.Label, n.Stmt)
+}
+
 /* Block Statement */
 
 type BlockStmt struct {
@@ -689,21 +1540,25 @@ func (n *ProgramNode) String() string {
 type FuncDefNode struct {
 	Body      *BlockStmt
 	Name      string
+	IsStatic  bool
 	Type      types.Type
-	Offsets   map[string]int
 	StackSize int
 	Args      *FuncDefArgs
 	OffsetCnt int
-	Locals    map[string]*LocalVariable
+	maxOffset int
 	token     *token.Token
+
+	// IsVariadic is set by f(int a, ...): more arguments of any type may
+	// follow the parameters.
+	IsVariadic bool
+
+	// NoProto is set by a declaration int f(); without a prototype. It
+	// says nothing about the parameters.
+	NoProto bool
 }
 
 func NewFuncDefNode(token *token.Token) *FuncDefNode {
-	return &FuncDefNode{
-		token:   token,
-		Offsets: map[string]int{},
-		Locals:  map[string]*LocalVariable{},
-	}
+	return &FuncDefNode{token: token}
 }
 
 func (n *FuncDefNode) Token() *token.Token {
@@ -712,6 +1567,9 @@ func (n *FuncDefNode) Token() *token.Token {
 
 func (n *FuncDefNode) String() string {
 	var out bytes.Buffer
+	if n.IsStatic {
+		out.WriteString("static ")
+	}
 	out.WriteString(n.Type.String())
 	out.WriteString(" ")
 	out.WriteString(n.Name)
@@ -722,15 +1580,18 @@ func (n *FuncDefNode) String() string {
 	return out.String()
 }
 
-func (n *FuncDefNode) PrepareStackSize() {
-	max := 0
-	for _, v := range n.Offsets {
-		if v > max {
-			max = v
-		}
+// Alloc puts local on the top of the stack slots in use, aligned to
+// its type.
+func (n *FuncDefNode) Alloc(local *LocalVariable) {
+	n.OffsetCnt = alignTo(n.OffsetCnt, types.Align(local.Type)) + local.Type.StackSize()
+	local.Offset = n.OffsetCnt
+	if n.OffsetCnt > n.maxOffset {
+		n.maxOffset = n.OffsetCnt
 	}
+}
 
-	n.StackSize = alignTo(max, 16)
+func (n *FuncDefNode) PrepareStackSize() {
+	n.StackSize = alignTo(n.maxOffset, 16)
 }
 
 /* Array Literal */
@@ -751,12 +1612,20 @@ func NewArrayLiteral(ident *IdentExp, token *token.Token) *ArrayLiteral {
 
 func (n *ArrayLiteral) expNode() {}
 
+// AsInfixExps returns the assignments of the elements in order. The
+// elements of a struct initializer go to the members.
 func (n *ArrayLiteral) AsInfixExps() []*InfixExp {
 	infixes := []*InfixExp{}
+	st, isStruct := n.Ident.Type().(*types.Struct)
 	for i, exp := range n.Exps {
-		num := NewNumExp(i, exp.Token())
-		index := NewIndexExp(n.Ident, num, exp.Token())
-		infix := NewInfixExp(index, exp, "=", exp.Token())
+		var left Exp
+		if isStruct {
+			left = NewMemberExp(n.Ident, ".", st.Members[i], exp.Token())
+		} else {
+			num := NewNumExp(i, exp.Token())
+			left = NewIndexExp(n.Ident, num, exp.Token())
+		}
+		infix := NewInfixExp(left, exp, "=", exp.Token())
 		infixes = append(infixes, infix)
 	}
 
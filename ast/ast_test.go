@@ -0,0 +1,119 @@
+package ast
+
+import (
+	"go9cc/token"
+	"go9cc/types"
+	"testing"
+)
+
+func TestEvalCompare(t *testing.T) {
+	tkn := &token.Token{}
+	num := func(val int) Exp { return NewNumExp(val, tkn) }
+	unum := func(val int) Exp { return NewTypedNumExp(val, tkn, types.GetUInt()) }
+	lnum := func(val int) Exp { return NewTypedNumExp(val, tkn, types.GetULong()) }
+
+	tests := []struct {
+		left, right Exp
+		op          string
+		want        int
+	}{
+		{num(1), num(1), "==", 1},
+		{num(1), num(2), "==", 0},
+		{num(-1), unum(4294967295), "==", 1},
+		{num(1), num(2), "!=", 1},
+		{num(2), num(2), "!=", 0},
+		{num(1), num(2), "<", 1},
+		{num(2), num(2), "<", 0},
+		{num(-1), num(1), "<", 1},
+		{num(-1), unum(1), "<", 0},
+		{num(-1), lnum(1), "<", 0},
+		{num(2), num(2), "<=", 1},
+		{num(3), num(2), "<=", 0},
+		{num(-1), unum(1), "<=", 0},
+		{num(3), num(2), ">", 1},
+		{num(2), num(2), ">", 0},
+		{num(-1), unum(1), ">", 1},
+		{num(2), num(2), ">=", 1},
+		{num(1), num(2), ">=", 0},
+		{num(-1), unum(1), ">=", 1},
+		{num(1), num(2), "&&", 1},
+		{num(1), num(0), "&&", 0},
+		{num(0), num(1), "&&", 0},
+		{num(0), num(0), "||", 0},
+		{num(0), num(-3), "||", 1},
+		{num(2), num(0), "||", 1},
+	}
+
+	for i, tt := range tests {
+		exp := NewInfixExp(tt.left, tt.right, tt.op, tkn)
+		got, err := Eval(exp)
+		if err != nil {
+			t.Fatalf("%d: %s: unexpected error: %s", i, exp, err)
+		}
+
+		if got.Val != tt.want {
+			t.Errorf("%d: %s: %d expected, but got=%d", i, exp, tt.want, got.Val)
+		}
+
+		if got.Type() != types.GetInt() {
+			t.Errorf("%d: %s: int expected, but got=%s", i, exp, got.Type())
+		}
+	}
+}
+
+func TestEvalNot(t *testing.T) {
+	tkn := &token.Token{}
+	tests := []struct {
+		right Exp
+		want  int
+	}{
+		{NewNumExp(0, tkn), 1},
+		{NewNumExp(1, tkn), 0},
+		{NewNumExp(-5, tkn), 0},
+		{NewTypedNumExp(4294967296, tkn, types.GetLong()), 0},
+		{NewUnaryExp(NewNumExp(7, tkn), "!", tkn), 1},
+	}
+
+	for i, tt := range tests {
+		exp := NewUnaryExp(tt.right, "!", tkn)
+		got, err := Eval(exp)
+		if err != nil {
+			t.Fatalf("%d: %s: unexpected error: %s", i, exp, err)
+		}
+
+		if got.Val != tt.want {
+			t.Errorf("%d: %s: %d expected, but got=%d", i, exp, tt.want, got.Val)
+		}
+	}
+}
+
+// TestEvalShortCircuit checks that the right side is not evaluated if the
+// left side decides the result, as at run time.
+func TestEvalShortCircuit(t *testing.T) {
+	tkn := &token.Token{}
+	divByZero := NewInfixExp(NewNumExp(1, tkn), NewNumExp(0, tkn), "/", tkn)
+	tests := []struct {
+		left Exp
+		op   string
+		want int
+	}{
+		{NewNumExp(0, tkn), "&&", 0},
+		{NewNumExp(3, tkn), "||", 1},
+	}
+
+	for i, tt := range tests {
+		exp := NewInfixExp(tt.left, divByZero, tt.op, tkn)
+		got, err := Eval(exp)
+		if err != nil {
+			t.Fatalf("%d: %s: unexpected error: %s", i, exp, err)
+		}
+
+		if got.Val != tt.want {
+			t.Errorf("%d: %s: %d expected, but got=%d", i, exp, tt.want, got.Val)
+		}
+	}
+
+	if _, err := Eval(NewInfixExp(NewNumExp(1, tkn), divByZero, "&&", tkn)); err == nil {
+		t.Errorf("division by zero on the right side of 1 && must fail")
+	}
+}
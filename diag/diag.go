@@ -0,0 +1,211 @@
+package diag
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Note
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	case Note:
+		return "note"
+	}
+
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// Diagnostic codes. They name the class of a problem so that tools can
+// filter on them without parsing messages.
+const (
+	Lex          = "lex"
+	Syntax       = "syntax"
+	Type         = "type"
+	Undefined    = "undefined"
+	Redefined    = "redefined"
+	ImplicitDecl = "implicit-decl" // a call to an undeclared function
+	Codegen      = "codegen"
+)
+
+/* Source File */
+
+// File is a source file. The line table is built on first use.
+type File struct {
+	Name string
+	Code []rune
+
+	once  sync.Once
+	lines []int // offsets of the first rune of each line
+}
+
+func NewFile(name, code string) *File {
+	return &File{Name: name, Code: []rune(code)}
+}
+
+func (f *File) lineStarts() []int {
+	f.once.Do(func() {
+		f.lines = []int{0}
+		for i, ch := range f.Code {
+			if ch == '\n' {
+				f.lines = append(f.lines, i+1)
+			}
+		}
+	})
+	return f.lines
+}
+
+// Position returns 0-based line and column of the rune offset.
+func (f *File) Position(offset int) (int, int) {
+	lines := f.lineStarts()
+	row := sort.Search(len(lines), func(i int) bool { return lines[i] > offset }) - 1
+	if row < 0 {
+		row = 0
+	}
+	return row, offset - lines[row]
+}
+
+// Line returns the 0-based line of the file without its newline.
+func (f *File) Line(row int) string {
+	lines := f.lineStarts()
+	if row < 0 || row >= len(lines) {
+		return ""
+	}
+
+	start := lines[row]
+	end := len(f.Code)
+	if row+1 < len(lines) {
+		end = lines[row+1] - 1
+	}
+	return string(f.Code[start:end])
+}
+
+/* Position */
+
+// Pos is a rune offset in a source file. Line and column are resolved
+// from the file's line table only when asked for.
+type Pos struct {
+	File   *File
+	Offset int
+}
+
+func (p Pos) IsValid() bool {
+	return p.File != nil
+}
+
+// Line returns the 1-based line number.
+func (p Pos) Line() int {
+	if !p.IsValid() {
+		return 0
+	}
+	row, _ := p.File.Position(p.Offset)
+	return row + 1
+}
+
+// Column returns the 1-based column number counted in runes.
+func (p Pos) Column() int {
+	if !p.IsValid() {
+		return 0
+	}
+	_, col := p.File.Position(p.Offset)
+	return col + 1
+}
+
+func (p Pos) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+
+	name := p.File.Name
+	if name == "" {
+		name = "<input>"
+	}
+	return fmt.Sprintf("%s:%d:%d", name, p.Line(), p.Column())
+}
+
+/* Diagnostic */
+
+type Diagnostic struct {
+	Pos      Pos
+	Severity Severity
+	Code     string
+	Message  string
+	Notes    []*Diagnostic
+}
+
+func Errorf(pos Pos, code string, msg string, args ...interface{}) *Diagnostic {
+	return &Diagnostic{Pos: pos, Severity: Error, Code: code, Message: fmt.Sprintf(msg, args...)}
+}
+
+func Warningf(pos Pos, code string, msg string, args ...interface{}) *Diagnostic {
+	return &Diagnostic{Pos: pos, Severity: Warning, Code: code, Message: fmt.Sprintf(msg, args...)}
+}
+
+// Notef attaches a note pointing at pos, e.g. a previous declaration.
+func (d *Diagnostic) Notef(pos Pos, msg string, args ...interface{}) *Diagnostic {
+	note := &Diagnostic{Pos: pos, Severity: Note, Message: fmt.Sprintf(msg, args...)}
+	d.Notes = append(d.Notes, note)
+	return d
+}
+
+func (d *Diagnostic) Error() string {
+	if !d.Pos.IsValid() {
+		return fmt.Sprintf("%s: %s", d.Severity, d.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", d.Pos, d.Severity, d.Message)
+}
+
+// List is a list of diagnostics in the order they were reported.
+type List []*Diagnostic
+
+func (l List) Error() string {
+	ss := []string{}
+	for _, d := range l {
+		ss = append(ss, d.Error())
+	}
+	return strings.Join(ss, "\n")
+}
+
+// Err returns the list as an error if it holds an error, or nil.
+func (l List) Err() error {
+	if l.HasError() {
+		return l
+	}
+	return nil
+}
+
+// AsList returns the diagnostics carried by err. Errors that are not
+// diagnostics become a single diagnostic without position.
+func AsList(err error) List {
+	switch err := err.(type) {
+	case nil:
+		return nil
+	case List:
+		return err
+	case *Diagnostic:
+		return List{err}
+	}
+
+	return List{&Diagnostic{Severity: Error, Message: err.Error()}}
+}
+
+func (l List) HasError() bool {
+	for _, d := range l {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package diag
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestFilePosition(t *testing.T) {
+	tests := []struct {
+		code  string
+		idx   int
+		wants string
+		wantr int
+		wantc int
+	}{
+		{"aaa\nbbb\nccc", 0, "aaa", 0, 0},
+		{"aaa\nbbb\nccc", 1, "aaa", 0, 1},
+		{"aaa\nbbb\nccc", 3, "aaa", 0, 3},
+		{"aaa\nbbb\nccc", 4, "bbb", 1, 0},
+		{"aaa\nbbb\nccc", 6, "bbb", 1, 2},
+		{"aaa\nbbb\nccc", 8, "ccc", 2, 0},
+		{"aaa\nbbb\nccc", 10, "ccc", 2, 2},
+		{"aaa\nbbb\nccc", 11, "ccc", 2, 3}, // EOF
+		{"", 0, "", 0, 0},
+	}
+
+	for i, tt := range tests {
+		f := NewFile("a.c", tt.code)
+		row, col := f.Position(tt.idx)
+		line := f.Line(row)
+		if line != tt.wants {
+			t.Errorf("Case%d line: got=%s, want=%s\n", i, line, tt.wants)
+		}
+
+		if row != tt.wantr {
+			t.Errorf("Case%d  row: got=%d, want=%d\n", i, row, tt.wantr)
+		}
+
+		if col != tt.wantc {
+			t.Errorf("Case%d col: got=%d, want=%d\n", i, col, tt.wantc)
+		}
+	}
+}
+
+func TestCaret(t *testing.T) {
+	f := NewFile("a.c", "int x;\nint main() { return y; }\n")
+	d := Errorf(Pos{File: f, Offset: 27}, Undefined, "Ident %s not defined.", "y")
+	d.Notef(Pos{File: f, Offset: 4}, "did you mean x?")
+
+	out := &bytes.Buffer{}
+	if err := (&Caret{}).Render(out, List{d, Errorf(Pos{}, Codegen, "no position")}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `a.c:2: int main() { return y; }
+                           ^ error: Ident y not defined. [undefined]
+a.c:1: int x;
+           ^ note: did you mean x?
+error: no position [codegen]
+`
+	if out.String() != want {
+		t.Fatalf("Wrong caret output:\ngot =\n%s\nwant=\n%s", out.String(), want)
+	}
+}
+
+// TestCaretWide checks that the caret stays under the column after wide
+// runes and tabs.
+func TestCaretWide(t *testing.T) {
+	code := "int main() { /* 日本語 */ return y; }\n\tx = \"é\" + z;\n"
+	f := NewFile("a.c", code)
+	runes := []rune(code)
+	offset := func(s string, from int) int {
+		for i := from; i < len(runes); i++ {
+			if string(runes[i:i+len([]rune(s))]) == s {
+				return i
+			}
+		}
+		t.Fatalf("%s not found", s)
+		return 0
+	}
+
+	ds := List{
+		Errorf(Pos{File: f, Offset: offset("y", 0)}, Undefined, "Ident %s not defined.", "y"),
+		Errorf(Pos{File: f, Offset: offset("z", 0)}, Undefined, "Ident %s not defined.", "z"),
+	}
+	out := &bytes.Buffer{}
+	if err := (&Caret{}).Render(out, ds); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "a.c:1: int main() { /* 日本語 */ return y; }\n" +
+		"                                        ^ error: Ident y not defined. [undefined]\n" +
+		"a.c:2: \tx = \"é\" + z;\n" +
+		"       \t          ^ error: Ident z not defined. [undefined]\n"
+	if out.String() != want {
+		t.Fatalf("Wrong caret output:\ngot =\n%s\nwant=\n%s", out.String(), want)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	f := NewFile("a.c", "int x;\nint x;\n")
+	d := Errorf(Pos{File: f, Offset: 11}, Redefined, "Global variable already declared: x")
+	d.Notef(Pos{File: f, Offset: 4}, "previous declaration")
+	w := Warningf(Pos{File: f, Offset: 0}, Type, "unused")
+
+	out := &bytes.Buffer{}
+	if err := (&JSON{}).Render(out, List{d, w}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := []jsonDiagnostic{}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid json: %s\n%s", err, out.String())
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("2 diagnostics expected, but got=%d", len(got))
+	}
+
+	if got[0].File != "a.c" || got[0].Line != 2 || got[0].Column != 5 || got[0].Severity != "error" || got[0].Code != Redefined {
+		t.Errorf("Wrong diagnostic: %+v", got[0])
+	}
+
+	if len(got[0].Notes) != 1 || got[0].Notes[0].Line != 1 || got[0].Notes[0].Severity != "note" {
+		t.Errorf("Wrong notes: %+v", got[0].Notes)
+	}
+
+	if got[1].Severity != "warning" || got[1].Line != 1 || got[1].Column != 1 {
+		t.Errorf("Wrong diagnostic: %+v", got[1])
+	}
+}
@@ -0,0 +1,166 @@
+package diag
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"unicode"
+)
+
+type Renderer interface {
+	Render(w io.Writer, ds List) error
+}
+
+// NewRenderer returns the renderer for -fdiagnostics-format.
+func NewRenderer(format string) (Renderer, error) {
+	switch format {
+	case "", "caret":
+		return &Caret{}, nil
+	case "json":
+		return &JSON{}, nil
+	}
+
+	return nil, fmt.Errorf("unknown diagnostics format: %s", format)
+}
+
+/* Caret */
+
+// Caret prints the source line and points at the column:
+//
+//	line 1: int main() { return x; }
+//	                            ^ error: Ident x not defined.
+type Caret struct{}
+
+func (r *Caret) Render(w io.Writer, ds List) error {
+	var out bytes.Buffer
+	for _, d := range ds {
+		writeCaret(&out, d)
+		for _, note := range d.Notes {
+			writeCaret(&out, note)
+		}
+	}
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+func writeCaret(out *bytes.Buffer, d *Diagnostic) {
+	msg := fmt.Sprintf("%s: %s", d.Severity, d.Message)
+	if d.Code != "" {
+		msg += fmt.Sprintf(" [%s]", d.Code)
+	}
+
+	if !d.Pos.IsValid() {
+		out.WriteString(msg + "\n")
+		return
+	}
+
+	prefix := fmt.Sprintf("line %d: ", d.Pos.Line())
+	if d.Pos.File.Name != "" {
+		prefix = fmt.Sprintf("%s:%d: ", d.Pos.File.Name, d.Pos.Line())
+	}
+
+	line := []rune(d.Pos.File.Line(d.Pos.Line() - 1))
+	out.WriteString(prefix)
+	out.WriteString(string(line))
+	out.WriteString("\n")
+
+	// The caret goes under the rune at the column however wide the runes
+	// before it are shown.
+	before := append([]rune(prefix), line[:min(d.Pos.Column()-1, len(line))]...)
+	out.WriteString(padding(before))
+	out.WriteString("^ " + msg + "\n")
+}
+
+// padding returns the blank as wide as text on a terminal. Tabs are kept
+// so that they expand to the same width as in the line above.
+func padding(text []rune) string {
+	var out bytes.Buffer
+	for _, r := range text {
+		switch {
+		case r == '\t':
+			out.WriteRune('\t')
+		case unicode.Is(unicode.Mn, r):
+			// combining marks take no column
+		case isWide(r):
+			out.WriteString("  ")
+		default:
+			out.WriteByte(' ')
+		}
+	}
+	return out.String()
+}
+
+// isWide reports whether r takes two columns like CJK characters, kana,
+// Hangul, fullwidth forms and emoji.
+func isWide(r rune) bool {
+	switch {
+	case r >= 0x1100 && r <= 0x115F,
+		r >= 0x2E80 && r <= 0x303E,
+		r >= 0x3041 && r <= 0xA4CF,
+		r >= 0xAC00 && r <= 0xD7A3,
+		r >= 0xF900 && r <= 0xFAFF,
+		r >= 0xFE30 && r <= 0xFE4F,
+		r >= 0xFF00 && r <= 0xFF60,
+		r >= 0xFFE0 && r <= 0xFFE6,
+		r >= 0x1F300 && r <= 0x1F64F,
+		r >= 0x1F900 && r <= 0x1F9FF,
+		r >= 0x20000 && r <= 0x3FFFD:
+		return true
+	}
+	return false
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+/* JSON */
+
+// JSON prints all diagnostics as one JSON array for editors and CI.
+type JSON struct{}
+
+type jsonDiagnostic struct {
+	File     string            `json:"file"`
+	Line     int               `json:"line"`
+	Column   int               `json:"column"`
+	Offset   int               `json:"offset"`
+	Severity string            `json:"severity"`
+	Code     string            `json:"code,omitempty"`
+	Message  string            `json:"message"`
+	Notes    []*jsonDiagnostic `json:"notes,omitempty"`
+}
+
+func (r *JSON) Render(w io.Writer, ds List) error {
+	out := []*jsonDiagnostic{}
+	for _, d := range ds {
+		out = append(out, toJSON(d))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func toJSON(d *Diagnostic) *jsonDiagnostic {
+	j := &jsonDiagnostic{
+		Line:     d.Pos.Line(),
+		Column:   d.Pos.Column(),
+		Offset:   d.Pos.Offset,
+		Severity: d.Severity.String(),
+		Code:     d.Code,
+		Message:  d.Message,
+	}
+	if d.Pos.IsValid() {
+		j.File = d.Pos.File.Name
+	}
+
+	for _, note := range d.Notes {
+		j.Notes = append(j.Notes, toJSON(note))
+	}
+	return j
+}
@@ -0,0 +1,176 @@
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"go9cc/diag"
+	"go9cc/generator"
+	"go9cc/parser"
+	"go9cc/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Unit is a translation unit: one source file compiled to its own
+// assembly and object file, with its own symbol table.
+type Unit struct {
+	File     *diag.File
+	Asm      bytes.Buffer
+	Warnings diag.List
+	parser   *parser.Parser
+	err      error
+}
+
+type Driver struct {
+	ErrorLimit int
+	CC         string // assembler and linker driver
+}
+
+func New() *Driver {
+	return &Driver{ErrorLimit: parser.DefaultErrorLimit, CC: "cc"}
+}
+
+// Compile compiles each file into assembly concurrently. Errors of all
+// units are returned together in the order of files. The warnings of a
+// successful compile are left in Unit.Warnings.
+func (d *Driver) Compile(files []*diag.File) ([]*Unit, error) {
+	units := []*Unit{}
+	for _, file := range files {
+		units = append(units, &Unit{File: file})
+	}
+
+	var wg sync.WaitGroup
+	for _, unit := range units {
+		wg.Add(1)
+		go func(unit *Unit) {
+			defer wg.Done()
+			d.compile(unit)
+		}(unit)
+	}
+	wg.Wait()
+
+	errs := diag.List{}
+	for _, unit := range units {
+		errs = append(errs, diag.AsList(unit.err)...)
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return units, nil
+}
+
+func (d *Driver) compile(unit *Unit) {
+	tzer := token.NewFile(unit.File)
+	unit.parser = parser.New(tzer)
+	unit.parser.ErrorLimit = d.ErrorLimit
+	gen := generator.New(unit.parser, &unit.Asm)
+	unit.err = gen.Gen()
+	unit.Warnings = unit.parser.Warnings()
+}
+
+// CheckSymbols reports external symbols defined by more than one unit,
+// which would not link. Static symbols are private to their unit.
+func CheckSymbols(units []*Unit) error {
+	errs := diag.List{}
+	defined := map[string]*parser.Symbol{}
+	for _, unit := range units {
+		for _, sym := range unit.parser.Symbols() {
+			if sym.IsStatic {
+				continue
+			}
+
+			prev, ok := defined[sym.Name]
+			if !ok {
+				defined[sym.Name] = sym
+				continue
+			}
+
+			d := diag.Errorf(sym.Token.Pos(), diag.Redefined, "Duplicate symbol %s in multiple translation units.", sym.Name)
+			d.Notef(prev.Token.Pos(), "previous definition is here")
+			errs = append(errs, d)
+		}
+	}
+	return errs.Err()
+}
+
+// Link assembles the units into object files and links them with objs,
+// extra object files or libraries, into the executable output.
+func (d *Driver) Link(units []*Unit, objs []string, output string) error {
+	dir, err := ioutil.TempDir("", "gocc")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	unitObjs := make([]string, len(units))
+	for i, unit := range units {
+		// Prefixed with the index: a/x.c and b/x.c must not collide.
+		name := fmt.Sprintf("%d-%s.o", i, BaseName(unit.File.Name))
+		unitObjs[i] = filepath.Join(dir, name)
+	}
+
+	if err := d.AssembleAll(units, unitObjs); err != nil {
+		return err
+	}
+
+	args := append([]string{"-o", output}, unitObjs...)
+	args = append(args, objs...)
+	return d.run(nil, args...)
+}
+
+// AssembleAll assembles units[i] into objs[i] concurrently.
+func (d *Driver) AssembleAll(units []*Unit, objs []string) error {
+	errs := make([]error, len(units))
+
+	var wg sync.WaitGroup
+	for i, unit := range units {
+		wg.Add(1)
+		go func(i int, unit *Unit) {
+			defer wg.Done()
+			errs[i] = d.Assemble(unit, objs[i])
+		}(i, unit)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Assemble writes the object file of the unit with the system assembler.
+func (d *Driver) Assemble(unit *Unit, obj string) error {
+	return d.run(bytes.NewReader(unit.Asm.Bytes()), "-x", "assembler", "-c", "-o", obj, "-")
+}
+
+func (d *Driver) run(stdin io.Reader, args ...string) error {
+	cmd := exec.Command(d.CC, args...)
+	cmd.Stdin = stdin
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return &ToolError{fmt.Sprintf("%s %s: %s\n%s", d.CC, strings.Join(args, " "), err, out)}
+	}
+	return nil
+}
+
+// ToolError is a failure of the assembler or the linker.
+type ToolError struct {
+	msg string
+}
+
+func (e *ToolError) Error() string {
+	return e.msg
+}
+
+// BaseName returns the file name without directory and extension, the
+// default name of the outputs compiled from it.
+func BaseName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
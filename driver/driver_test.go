@@ -0,0 +1,73 @@
+package driver
+
+import (
+	"go9cc/diag"
+	"testing"
+)
+
+func TestCheckSymbols(t *testing.T) {
+	tests := []struct {
+		sources []string
+		errs    []string
+	}{
+		{
+			[]string{
+				"static int x; static int f() { return 1; } int main() { return f(); }",
+				"static int x; static int f() { return 2; } int g() { return f(); }",
+			},
+			[]string{},
+		},
+		{
+			[]string{
+				"enum { A, B }; int main() { return B; }",
+				"enum { A, B }; int g() { return A; }",
+			},
+			[]string{},
+		},
+		{
+			[]string{
+				"int f(int a); int main() { return f(1); }",
+				"int f(int a); int f(int a) { return a; }",
+			},
+			[]string{},
+		},
+		{
+			[]string{
+				"int x; int main() { return 0; }",
+				"int x; int g() { return 0; }",
+				"int g() { return 1; }",
+			},
+			[]string{
+				"b.c:1:5: error: Duplicate symbol x in multiple translation units.",
+				"c.c:1:5: error: Duplicate symbol g in multiple translation units.",
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		files := []*diag.File{}
+		for j, src := range tt.sources {
+			files = append(files, diag.NewFile(string(rune('a'+j))+".c", src))
+		}
+
+		units, err := New().Compile(files)
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+
+		ds := diag.AsList(CheckSymbols(units))
+		if len(ds) != len(tt.errs) {
+			t.Fatalf("%d: %d errors expected, but got=%d:\n%s", i, len(tt.errs), len(ds), ds)
+		}
+
+		for j, want := range tt.errs {
+			if ds[j].Error() != want {
+				t.Errorf("%d: want=%s, but got=%s", i, want, ds[j].Error())
+			}
+
+			if len(ds[j].Notes) != 1 {
+				t.Errorf("%d: a note on the previous definition is expected", i)
+			}
+		}
+	}
+}
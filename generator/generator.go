@@ -3,18 +3,20 @@ package generator
 import (
 	"fmt"
 	"go9cc/ast"
+	"go9cc/diag"
 	"go9cc/parser"
 	"go9cc/token"
 	"go9cc/types"
 	"go9cc/writer"
 	"io"
+	"math"
 	"os"
 )
 
-const (
-	DEBUG        = true
-	INTEL_SYNTAX = true
-)
+const INTEL_SYNTAX = true
+
+// DEBUG traces the code generation on stderr.
+var DEBUG = false
 
 const (
 	RIP = "rip"
@@ -36,6 +38,14 @@ const (
 	R8D = "r8d" // 5th param
 	R9D = "r9d" // 6th param
 
+	AX  = "ax"
+	DI  = "di"  // 1st param
+	SI  = "si"  // 2nd param
+	DX  = "dx"  // 3rd param
+	CX  = "cx"  // 4th param
+	R8W = "r8w" // 5th param
+	R9W = "r9w" // 6th param
+
 	AL  = "al"
 	DIL = "dil"
 	SIL = "sil"
@@ -57,6 +67,16 @@ var DWORD = map[string]string{
 	R9:  R9D,
 }
 
+var WORD = map[string]string{
+	RAX: AX,
+	RDI: DI,
+	RSI: SI,
+	RDX: DX,
+	RCX: CX,
+	R8:  R8W,
+	R9:  R9W,
+}
+
 var BYTE = map[string]string{
 	RAX: AL,
 	RDI: DIL,
@@ -73,6 +93,10 @@ type Generator struct {
 	lblCnt    int
 	currentFn *ast.FuncDefNode
 	fns       map[string]*ast.FuncDefNode
+	breaks    []string // breakの飛び先。内側のループやswitchほど後ろ
+	continues []string // continueの飛び先
+	caseLbls  map[*ast.CaseStmt]string
+	tkn       *token.Token // 生成中のノードの位置。内部エラーの位置に使う
 }
 
 func New(p *parser.Parser, out io.Writer) *Generator {
@@ -81,6 +105,8 @@ func New(p *parser.Parser, out io.Writer) *Generator {
 		parser: p,
 		writer: writer,
 		fns:    map[string]*ast.FuncDefNode{},
+
+		caseLbls: map[*ast.CaseStmt]string{},
 	}
 	return gen
 }
@@ -93,20 +119,56 @@ func (g *Generator) strings() []*ast.StringLiteralExp {
 	return g.parser.Strings
 }
 
-func (g *Generator) Gen() {
+// Gen writes the assembly of the parsed program. Nothing is written
+// to out if an error occurs.
+func (g *Generator) Gen() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = g.catch(r)
+		}
+	}()
+
 	g.writer.Header()
 
-	node := g.parser.Parse()
+	node, err := g.parser.Parse()
+	if err != nil {
+		return err
+	}
 
 	for _, str := range g.strings() {
 		g.strDef(str)
 	}
 	g.walk(node)
 	g.writer.Commit()
+	return nil
 }
 
-func (g *Generator) Error(token *token.Token, msg string, args ...interface{}) {
-	g.parser.Error(token, msg, args...)
+// Error returns a compile error located at token.
+func (g *Generator) Error(token *token.Token, msg string, args ...interface{}) *diag.Diagnostic {
+	d := g.parser.Error(token, msg, args...)
+	d.Code = diag.Codegen
+	return d
+}
+
+// fail aborts code generation with a compile error located at token.
+// Gen recovers it and returns it to the caller.
+func (g *Generator) fail(token *token.Token, msg string, args ...interface{}) {
+	panic(g.Error(token, msg, args...))
+}
+
+// catch turns a value recovered from an aborted generation into an error.
+// Anything else than a compile error is a bug and keeps panicking.
+func (g *Generator) catch(r interface{}) error {
+	switch r := r.(type) {
+	case *diag.Diagnostic:
+		return r
+	case *ast.TypeError:
+		d := g.Error(r.Token(), "%s", r.Error())
+		d.Code = diag.Type
+		return d
+	}
+
+	panic(r)
 }
 
 // push corresponding address to the top of stack
@@ -116,7 +178,20 @@ func (g *Generator) address(fn *ast.FuncDefNode, node interface{}) {
 	case *ast.IndexExp:
 		offset, base := g.getOffset(fn, ty.Ident)
 		g.walk(ty.Index) // 結果の数値がRAXに乗る
-		src := g.writer.Index(base, RAX, ty.Type().StackSize())
+		if base == RIP {
+			// RIP相対はインデックスと組み合わせられないので先にアドレスを求める
+			g.writer.Lea(offset, RIP, RDI)
+			offset, base = "", RDI
+		}
+		size := ty.Type().StackSize()
+		switch size {
+		case 1, 2, 4, 8:
+		default:
+			// 構造体の大きさはインデックスの倍率に使えない
+			g.writer.Mul(fmt.Sprint(size), RAX)
+			size = 1
+		}
+		src := g.writer.Index(base, RAX, size)
 		g.writer.Lea(offset, src, RAX)
 		return
 	case *ast.UnaryExp:
@@ -133,65 +208,353 @@ func (g *Generator) address(fn *ast.FuncDefNode, node interface{}) {
 			return
 		} else {
 			g.writer.Lea(ty.Name, RIP, RAX)
+			return
 		}
 	case *ast.IdentExp:
 		offset, base := g.getOffset(fn, ty)
 		g.writer.Lea(offset, base, RAX)
 		return
+	case *ast.MemberExp:
+		if ty.Op == "->" {
+			g.walk(ty.Left) // ポインタの値が構造体のアドレス
+		} else {
+			g.address(fn, ty.Left)
+		}
+		if ty.Member.Offset > 0 {
+			g.writer.Add(fmt.Sprint(ty.Member.Offset), RAX)
+		}
+		return
 	}
 
-	debug("address must be a ident node, but got: %T", node)
-	os.Exit(1)
+	g.fail(tokenOf(node), "address must be a ident node, but got: %T", node)
+}
+
+// tokenOf returns the token of node to locate an error, or nil if node
+// is not a node.
+func tokenOf(node interface{}) *token.Token {
+	if node, ok := node.(ast.Node); ok {
+		return node.Token()
+	}
+	return nil
+}
+
+// load replaces the address in RAX by the value of ty at the address.
+// Integers narrower than 64 bits are extended like extend does.
+func (g *Generator) load(ty types.Type) {
+	switch ty.(type) {
+	case *types.Array, *types.Struct:
+		// 配列と構造体は先頭のアドレスとして扱う
+		return
+	case *types.Void:
+		// 値が無いので読まない
+		return
+	}
+
+	addr := g.writer.Address(RAX)
+	unsigned := types.IsUnsigned(ty)
+	switch {
+	case !types.IsInteger(ty) || ty.Size() == 8:
+		g.writer.Mov(addr, RAX)
+	case ty.Size() == 1 && unsigned:
+		g.writer.Movzx("BYTE PTR "+addr, EAX)
+	case ty.Size() == 1:
+		g.writer.Movsx("BYTE PTR "+addr, RAX)
+	case ty.Size() == 2 && unsigned:
+		g.writer.Movzx("WORD PTR "+addr, EAX)
+	case ty.Size() == 2:
+		g.writer.Movsx("WORD PTR "+addr, RAX)
+	case unsigned:
+		g.writer.Mov(addr, EAX) // 上位32bitは0になる
+	default:
+		g.writer.Movsxd("DWORD PTR "+addr, RAX)
+	}
+}
+
+// store writes the value of ty in RAX to the address in RDI. A struct
+// in RAX is its address, and is copied.
+func (g *Generator) store(ty types.Type) {
+	if _, ok := ty.(*types.Struct); ok {
+		g.copyStruct(ty)
+		return
+	}
+	g.writer.Mov(g.getReg(RAX, ty), g.writer.Address(RDI))
+}
+
+// copyStruct copies the struct of ty at the address in RAX to the
+// address in RDI. RAX is left pointing to the copy.
+func (g *Generator) copyStruct(ty types.Type) {
+	size := ty.StackSize()
+	for i := 0; i < size; {
+		// なるべく大きな単位で写す
+		var unit types.Type
+		switch {
+		case size-i >= 8:
+			unit = types.GetLong()
+		case size-i >= 4:
+			unit = types.GetInt()
+		case size-i >= 2:
+			unit = types.GetShort()
+		default:
+			unit = types.GetChar()
+		}
+
+		reg := g.getReg(RDX, unit)
+		g.writer.Mov(g.writer.Address(fmt.Sprintf("%s+%d", RAX, i)), reg)
+		g.writer.Mov(reg, g.writer.Address(fmt.Sprintf("%s+%d", RDI, i)))
+		i += unit.Size()
+	}
+	g.writer.Mov(RDI, RAX)
+}
+
+// extend sign or zero extends the integer of ty in the low bits of RAX
+// to 64 bits. Values in RAX are always kept extended, so that operands
+// of any width can be computed with the 64-bit registers.
+func (g *Generator) extend(ty types.Type) {
+	if !types.IsInteger(ty) {
+		return
+	}
+
+	unsigned := types.IsUnsigned(ty)
+	switch {
+	case ty.Size() == 1 && unsigned:
+		g.writer.Movzx(AL, EAX)
+	case ty.Size() == 1:
+		g.writer.Movsx(AL, RAX)
+	case ty.Size() == 2 && unsigned:
+		g.writer.Movzx(AX, EAX)
+	case ty.Size() == 2:
+		g.writer.Movsx(AX, RAX)
+	case ty.Size() == 4 && unsigned:
+		g.writer.Mov(EAX, EAX)
+	case ty.Size() == 4:
+		g.writer.Movsxd(EAX, RAX)
+	}
+}
+
+// cast converts the value of from in RAX to to. Nothing is emitted if
+// the extended value is already correct for to.
+func (g *Generator) cast(from, to types.Type) {
+	if !types.IsInteger(from) || !types.IsInteger(to) {
+		return
+	}
+
+	switch {
+	case to.Size() == 8:
+		// 64bitに拡張済み
+		return
+	case from.Size() < to.Size() && (types.IsUnsigned(from) || !types.IsUnsigned(to)):
+		// 狭い型の値はそのまま広い型の値になる
+		return
+	case from.Size() == to.Size() && types.IsUnsigned(from) == types.IsUnsigned(to):
+		return
+	}
+	g.extend(to)
 }
 
 func (g *Generator) global(node *ast.DeclarationStmt) {
 	for _, local := range node.LV.Locals {
-		g.writer.Globl(local.Name)
+		if !local.IsStatic {
+			g.writer.Globl(local.Name)
+		}
 		g.writer.Data()
+		g.writer.Label(local.Name)
 
-		tyStr := getType(local.Type.Size())
+		if node.Exp == nil || local != node.Target() {
+			g.zero(local.Type.StackSize())
+			continue
+		}
 
-		if node.Exp == nil {
-			s := fmt.Sprintf("%s 0", tyStr)
-			g.writer.Text(s)
-		} else {
-			switch ty := g.eval(node.Exp).(type) {
-			case *ast.NumExp:
-				s := fmt.Sprintf("%s %d", tyStr, ty.Val)
-				g.writer.Label(local.Name)
-				g.writer.Text(s)
-			case *ast.StringLiteralExp:
-				s := fmt.Sprintf("%s %s", tyStr, ty.Label)
-				g.writer.Label(local.Name)
-				g.writer.Text(s)
-			case *ast.ArrayLiteral:
-				g.writer.Label(local.Name)
-				for _, exp := range ty.Exps {
-					num, ok := exp.(*ast.NumExp)
-					if !ok {
-						g.Error(ty.Token(), "Invalid global rvalue.")
-					}
-
-					arrTy := local.Type.(*types.Array)
-					baseTyStr := getType(arrTy.Base.Size())
-					debug("%T", arrTy.Base)
-					s := fmt.Sprintf("%s %d", baseTyStr, num.Val)
-					g.writer.Text(s)
-				}
-			case *ast.UnaryExp:
-				r, _ := ty.Right.(*ast.IdentExp)
-				s := fmt.Sprintf("%s %s", tyStr, r.Name)
-				g.writer.Label(local.Name)
-				g.writer.Text(s)
+		g.initializer(local.Type, node.Exp)
+	}
+}
+
+// initializer writes the static data of exp as a value of ty. Array
+// elements not listed are filled with zero.
+func (g *Generator) initializer(ty types.Type, exp ast.Exp) {
+	if st, ok := ty.(*types.Struct); ok {
+		if lit, ok := exp.(*ast.ArrayLiteral); ok {
+			g.structInitializer(st, lit)
+			return
+		}
+		g.fail(exp.Token(), "Invalid initializer for %s: %s", ty, exp)
+	}
+
+	arr, ok := ty.(*types.Array)
+	if !ok {
+		g.scalar(ty, exp)
+		return
+	}
+
+	switch exp := exp.(type) {
+	case *ast.ArrayLiteral:
+		for _, elem := range exp.Exps {
+			g.initializer(arr.Base, elem)
+		}
+		g.zero(arr.StackSize() - len(exp.Exps)*arr.Base.StackSize())
+		return
+	case *ast.StringLiteralExp:
+		if types.IsChar(arr.Base) {
+			if len(exp.Val) == arr.Length {
+				// 終端のnullは入らない
+				g.writer.Ascii(exp.Val)
+				return
+			}
+			g.writer.String(exp.Val)
+			g.zero(arr.Length - exp.Length())
+			return
+		}
+	}
+
+	g.fail(exp.Token(), "Invalid initializer for %s: %s", ty, exp)
+}
+
+// structInitializer writes the members of st from the elements of lit
+// in order. Padding and members not listed are filled with zero.
+func (g *Generator) structInitializer(st *types.Struct, lit *ast.ArrayLiteral) {
+	pos := 0
+	for i, elem := range lit.Exps {
+		member := st.Members[i]
+		g.zero(member.Offset - pos)
+		g.initializer(member.Type, elem)
+		pos = member.Offset + member.Type.StackSize()
+	}
+	g.zero(st.StackSize() - pos)
+}
+
+// scalar writes a number or an address constant like &x + 1.
+func (g *Generator) scalar(ty types.Type, exp ast.Exp) {
+	tyStr := getType(ty.Size())
+	switch ty.(type) {
+	case *types.IntPointer:
+		if lbl, offset, ok := g.constAddr(exp); ok {
+			switch {
+			case offset > 0:
+				g.writer.Text(fmt.Sprintf("%s %s+%d", tyStr, lbl, offset))
+			case offset < 0:
+				g.writer.Text(fmt.Sprintf("%s %s%d", tyStr, lbl, offset))
 			default:
-				g.Error(node.Exp.Token(), "Cannot evaluate rvalue of %s:", node.Exp)
+				g.writer.Text(fmt.Sprintf("%s %s", tyStr, lbl))
 			}
+			return
 		}
 	}
+
+	num, ok := g.eval(exp).(*ast.NumExp)
+	if !ok {
+		g.fail(exp.Token(), "Invalid global rvalue: %s", exp)
+	}
+	g.writer.Text(fmt.Sprintf("%s %d", tyStr, num.Val))
+}
+
+// stringInitializer copies the characters of lit to the local array arr.
+// The rest of arr is filled with zero.
+func (g *Generator) stringInitializer(local *ast.LocalVariable, arr *types.Array, lit *ast.StringLiteralExp) {
+	g.memzero(local)
+
+	// char s[3] = "abc"; では終端のnullは入らない
+	n := lit.Length()
+	if n > arr.Length {
+		n = arr.Length
+	}
+	g.address(g.currentFn, local)
+	g.writer.Mov(RAX, RDI)
+	g.writer.Lea(lit.Label, RIP, RAX)
+	g.copyStruct(types.ArrayOf(types.GetChar(), n))
+}
+
+// memzero fills the local variable with zero bytes.
+func (g *Generator) memzero(local *ast.LocalVariable) {
+	g.address(g.currentFn, local)
+	g.writer.Mov(RAX, RDI)
+	g.writer.Mov(fmt.Sprint(local.Type.StackSize()), RCX)
+	g.writer.Mov("0", AL)
+	g.writer.RepStosb()
+}
+
+func (g *Generator) zero(size int) {
+	if size > 0 {
+		g.writer.Text(fmt.Sprintf(".zero %d", size))
+	}
 }
 
+// constAddr returns the label and the byte offset of an address constant.
+// It is not ok if exp is not computable at link time.
+func (g *Generator) constAddr(exp ast.Exp) (string, int, bool) {
+	switch exp := exp.(type) {
+	case *ast.StringLiteralExp:
+		return exp.Label, 0, true
+	case *ast.IdentExp:
+		// 配列はポインタとして扱われる
+		if _, ok := exp.Type().(*types.Array); ok {
+			return g.globalLabel(exp)
+		}
+	case *ast.UnaryExp:
+		if exp.Op != "&" {
+			break
+		}
+
+		switch right := exp.Right.(type) {
+		case *ast.IdentExp:
+			return g.globalLabel(right)
+		case *ast.IndexExp:
+			lbl, offset, ok := g.globalLabel(right.Ident)
+			idx, isNum := g.eval(right.Index).(*ast.NumExp)
+			if !ok || !isNum {
+				break
+			}
+			return lbl, offset + idx.Val*right.Type().StackSize(), true
+		}
+	case *ast.InfixExp:
+		if exp.Op != "+" && exp.Op != "-" {
+			break
+		}
+
+		lbl, offset, ok := g.constAddr(exp.Left)
+		if !ok {
+			break
+		}
+
+		num, ok := g.eval(exp.Right).(*ast.NumExp)
+		if !ok {
+			break
+		}
+
+		unit := 1
+		switch leftTy := exp.Left.Type().(type) {
+		case *types.IntPointer:
+			unit = leftTy.Base.StackSize()
+		case *types.Array:
+			unit = leftTy.Base.StackSize()
+		}
+
+		if exp.Op == "-" {
+			return lbl, offset - num.Val*unit, true
+		}
+		return lbl, offset + num.Val*unit, true
+	case *ast.CondExp:
+		cond, ok := g.eval(exp.Cond).(*ast.NumExp)
+		if !ok {
+			break
+		}
+
+		if cond.Val != 0 {
+			return g.constAddr(exp.Then)
+		}
+		return g.constAddr(exp.Else)
+	}
+
+	return "", 0, false
+}
+
+func (g *Generator) globalLabel(ident *ast.IdentExp) (string, int, bool) {
+	if _, ok := g.globals()[ident.Name]; !ok {
+		return "", 0, false
+	}
+	return ident.Name, 0, true
+}
+
+// String literals are local to the unit: their labels are not exported.
 func (g *Generator) strDef(node *ast.StringLiteralExp) {
-	g.writer.Globl(node.Label)
 	g.writer.Data()
 	g.writer.Size(node.Length())
 	g.writer.Label(node.Label)
@@ -201,18 +564,28 @@ func (g *Generator) strDef(node *ast.StringLiteralExp) {
 
 func (g *Generator) walk(node ast.Node) {
 	debug("walk:\t%T", node)
+	if tkn := node.Token(); tkn != nil {
+		prev := g.tkn
+		g.tkn = tkn
+		defer func() { g.tkn = prev }()
+	}
+
 	switch ty := node.(type) {
 	case *ast.ProgramNode:
 		for _, stmt := range ty.GlobalStmts {
 			g.global(stmt)
 		}
+		g.writer.Text(".text")
 		for _, stmt := range ty.FuncDefs {
 			g.walk(stmt)
 		}
 	case *ast.ExpStmt:
 		g.walk(ty.Exp)
 	case *ast.ReturnStmt:
-		g.walk(ty.Exp)
+		if ty.Exp != nil {
+			g.walk(ty.Exp)
+			g.cast(ty.Exp.Type(), g.currentFn.Type)
+		}
 		s := fmt.Sprintf(".L.return.%s", g.currentFn.Name)
 		g.writer.Jmp(s)
 	case *ast.StmtListNode:
@@ -224,6 +597,7 @@ func (g *Generator) walk(node ast.Node) {
 	case *ast.ForStmt:
 		stmt, _ := node.(*ast.ForStmt)
 		lblBegin := g.genLbl()
+		lblContinue := g.genLbl()
 		lblEnd := g.genLbl()
 		if stmt.Init != nil {
 			g.walk(stmt.Init)
@@ -234,7 +608,8 @@ func (g *Generator) walk(node ast.Node) {
 			g.writer.Cmp("0", RAX)
 			g.writer.Je(lblEnd) // RAXが0(false)ならforの外にジャンプ
 		}
-		g.walk(stmt.Body)
+		g.loopBody(stmt.Body, lblEnd, lblContinue)
+		g.writer.Label(lblContinue) // continueしてもAfterEachは実行する
 		if stmt.AfterEach != nil {
 			g.walk(stmt.AfterEach)
 		}
@@ -248,9 +623,40 @@ func (g *Generator) walk(node ast.Node) {
 		g.walk(stmt.Cond)
 		g.writer.Cmp("0", RAX)
 		g.writer.Je(lblEnd) // RAXが0(false)ならwhileの外にジャンプ
-		g.walk(stmt.Body)
+		g.loopBody(stmt.Body, lblEnd, lblBegin)
 		g.writer.Jmp(lblBegin)
 		g.writer.Label(lblEnd)
+	case *ast.DoWhileStmt:
+		lblBegin := g.genLbl()
+		lblContinue := g.genLbl()
+		lblEnd := g.genLbl()
+		g.writer.Label(lblBegin)
+		g.loopBody(ty.Body, lblEnd, lblContinue)
+		g.writer.Label(lblContinue) // continueしても条件は評価する
+		g.walk(ty.Cond)
+		g.writer.Cmp("0", RAX)
+		g.writer.Jne(lblBegin) // RAXが0(false)でなければ先頭に戻る
+		g.writer.Label(lblEnd)
+	case *ast.SwitchStmt:
+		g.switchStmt(ty)
+	case *ast.CaseStmt:
+		g.writer.Label(g.caseLbls[ty])
+		g.walk(ty.Stmt)
+	case *ast.BreakStmt:
+		if len(g.breaks) == 0 {
+			g.fail(ty.Token(), "break statement not within loop or switch")
+		}
+		g.writer.Jmp(g.breaks[len(g.breaks)-1])
+	case *ast.ContinueStmt:
+		if len(g.continues) == 0 {
+			g.fail(ty.Token(), "continue statement not within a loop")
+		}
+		g.writer.Jmp(g.continues[len(g.continues)-1])
+	case *ast.GotoStmt:
+		g.writer.Jmp(g.userLbl(ty.Label))
+	case *ast.LabeledStmt:
+		g.writer.Label(g.userLbl(ty.Label))
+		g.walk(ty.Stmt)
 	case *ast.IfStmt:
 		stmt, _ := node.(*ast.IfStmt)
 		lblElse := g.genLbl()
@@ -265,26 +671,36 @@ func (g *Generator) walk(node ast.Node) {
 			g.walk(stmt.ElseBody)
 		}
 		g.writer.Label(lblEnd)
+	case *ast.CondExp:
+		lblElse := g.genLbl()
+		lblEnd := g.genLbl()
+		g.walk(ty.Cond)
+		g.writer.Cmp("0", RAX)
+		g.writer.Je(lblElse) // RAXが0(false)ならelse側を評価する
+		g.walk(ty.Then)
+		g.cast(ty.Then.Type(), ty.Type())
+		g.writer.Jmp(lblEnd)
+		g.writer.Label(lblElse)
+		g.walk(ty.Else)
+		g.cast(ty.Else.Type(), ty.Type())
+		g.writer.Label(lblEnd)
+	case *ast.CommaExp:
+		// 左の値は捨てる
+		g.walk(ty.Left)
+		g.walk(ty.Right)
 	case *ast.NumExp:
 		val := fmt.Sprintf("%d", ty.Val)
-		g.writer.Mov(val, getReg(RAX, ty.Type()))
+		g.writer.Mov(val, RAX)
 	case *ast.IndexExp:
 		g.address(g.currentFn, ty) // 配列のあるインデックスのアドレスがRAXに乗る
-
-		if ty.Type() == types.GetChar() {
-			g.writer.Movsx("BYTE PTR "+g.writer.Address(RAX), EAX)
-		} else {
-			g.writer.Mov(g.writer.Address(RAX), getReg(RAX, ty.Type()))
-		}
+		g.load(ty.Type())
 	case *ast.IdentExp:
 		// 変数呼び出し
 		g.address(g.currentFn, ty)
-
-		if ty.Type() == types.GetChar() {
-			g.writer.Movsx("BYTE PTR "+g.writer.Address(RAX), EAX)
-		} else {
-			g.writer.Mov(g.writer.Address(RAX), getReg(RAX, ty.Type()))
-		}
+		g.load(ty.Type())
+	case *ast.MemberExp:
+		g.address(g.currentFn, ty)
+		g.load(ty.Type())
 	case *ast.StringLiteralExp:
 		g.writer.Lea(ty.Label, RIP, RAX)
 	case *ast.ArrayLiteral:
@@ -292,46 +708,45 @@ func (g *Generator) walk(node ast.Node) {
 			g.walk(exp)
 		}
 	case *ast.FuncCallExp:
-		// 関数呼び出し
-		defined := ty.Def != nil
-		if !defined {
-			// コンパイル時点では定義なしでも、リンクされるので問題無し
-		}
-
-		for i, param := range ty.Params.Exps {
-			if defined {
-				arg := ty.Def.Args.LV.Locals[i]
-				if !arg.Type.CanAssign(param.Type()) {
-					g.Error(
-						param.Token(),
-						"Param types do not match for %s. Expected %s, but got %s.",
-						arg.Name, arg.Type.String(), param.Type().String(),
-					)
-				}
-			}
+		// 関数呼び出し. 引数の数と型はパーサーで検査済み.
+		// 宣言の無い関数や...に対応する引数は変換せずに渡す
+		nargs := 0
+		if ty.Def != nil {
+			nargs = len(ty.Def.Args.LV.Locals)
+		}
 
-			g.walk(param)
-			if i >= len(FUNCCALLREGS) {
-				g.writer.Push(RAX)
-			} else {
-				g.writer.Mov(getReg(RAX, param.Type()), getReg(FUNCCALLREGS[i], param.Type()))
+		// 後の引数の評価で前の引数のレジスタが壊れないよう、後ろから全部
+		// スタックに積んでからレジスタに移す. 7個目以降はスタックに残る
+		params := ty.Params.Exps
+		for i := len(params) - 1; i >= 0; i-- {
+			g.walk(params[i])
+			if i < nargs {
+				g.cast(params[i].Type(), ty.Def.Args.LV.Locals[i].Type)
 			}
+			g.writer.Push(RAX)
+		}
+		for i := 0; i < len(params) && i < len(FUNCCALLREGS); i++ {
+			g.writer.Pop(FUNCCALLREGS[i])
 		}
 
 		// 可変長引数を受け取る関数を呼ぶ前にALに浮動小数点数型の引数の数を渡す
 		g.writer.Mov("0", AL)
 		// FIXME: need align before call?
 		g.writer.Call(ty.Name)
+		// 呼び出し先が戻り値を拡張しているとは限らない
+		g.extend(ty.Type())
 	case *ast.FuncDefNode:
 		g.fns[ty.Name] = ty
 		g.currentFn = ty
-		g.writer.Globl(ty.Name)
+		if !ty.IsStatic {
+			g.writer.Globl(ty.Name)
+		}
 		g.writer.Label(ty.Name)
 		g.prolog()
 
 		fn, ok := g.fns[ty.Name]
 		if !ok {
-			g.Error(ty.Token(), "Function %s not found.\n", ty.Name)
+			g.fail(ty.Token(), "Function %s not found.", ty.Name)
 		}
 
 		// Prepare params
@@ -340,10 +755,10 @@ func (g *Generator) walk(node ast.Node) {
 			if i >= len(FUNCCALLREGS) {
 				g.writer.Pop(RDI)
 				g.writer.Lea(offset, RBP, RAX)
-				g.writer.Mov(getReg(RDI, local.Type), g.writer.Address(RAX))
+				g.writer.Mov(g.getReg(RDI, local.Type), g.writer.Address(RAX))
 			} else {
 				g.writer.Lea(offset, base, RAX)
-				g.writer.Mov(getReg(FUNCCALLREGS[i], local.Type), g.writer.Address(RAX))
+				g.writer.Mov(g.getReg(FUNCCALLREGS[i], local.Type), g.writer.Address(RAX))
 			}
 		}
 
@@ -357,32 +772,64 @@ func (g *Generator) walk(node ast.Node) {
 			g.address(g.currentFn, ty.Right) // RAXに目標のアドレスが載る
 		case "*":
 			g.walk(ty.Right) // RAXに目標のアドレスが載る
-			g.writer.Mov(g.writer.Address(RAX), getReg(RAX, ty.Right.Type()))
+			g.load(ty.Type())
 		case "+":
-			// do nothing ( +5 -> 5)
+			// +5 -> 5
+			g.walk(ty.Right)
 		case "-":
 			g.walk(ty.Right)
 			g.writer.Neg(RAX)
+			g.extend(ty.Type())
 		case "sizeof":
 			size := reduceSizeof(ty)
 			g.writer.Mov(fmt.Sprintf("%d", size), EAX)
+		case "++":
+			g.assignOp(ty.Right, "+", ast.NewNumExp(1, ty.Token()))
+		case "--":
+			g.assignOp(ty.Right, "-", ast.NewNumExp(1, ty.Token()))
+		case "~":
+			g.walk(ty.Right)
+			g.writer.Not(RAX)
+			g.extend(ty.Type())
+		case "!":
+			g.walk(ty.Right)
+			g.writer.Cmp("0", RAX)
+			g.writer.Sete(AL)
+			g.writer.Movzb(AL, RAX)
 		}
+	case *ast.PostfixExp:
+		// x++は(x += 1) - 1として古い値を返す
+		op, undo := "+", "-"
+		if ty.Op == "--" {
+			op, undo = "-", "+"
+		}
+		g.assignOp(ty.Left, op, ast.NewNumExp(1, ty.Token()))
+		g.writer.Mov("1", RDI)
+		g.binop(undo, ty.Left.Type(), ty.Type())
 	case *ast.DeclarationStmt:
-		for _, local := range ty.LV.Locals {
-			if ty.Exp != nil {
-				// XXX: ここでよいのか？
-				// 左辺値のアドレスが必要な場合のみアドレスをRAXにのせる
-				switch ty.Exp.(type) {
-				case *ast.ArrayLiteral:
-					g.walk(ty.Exp)
-				default:
-					g.address(g.currentFn, local)
-					g.writer.Push(RAX) // 直近2つのRAXが必要な場合は前のRAXをスタックに退避
-					g.walk(ty.Exp)
-					g.writer.Pop(RDI)
-					g.writer.Mov(getReg(RAX, local.Type), g.writer.Address(RDI))
+		if ty.Exp != nil {
+			local := ty.Target()
+			// XXX: ここでよいのか？
+			// 左辺値のアドレスが必要な場合のみアドレスをRAXにのせる
+			if lit, ok := ty.Exp.(*ast.StringLiteralExp); ok {
+				if arr, ok := local.Type.(*types.Array); ok {
+					g.stringInitializer(local, arr, lit)
+					break
 				}
 			}
+
+			switch ty.Exp.(type) {
+			case *ast.ArrayLiteral:
+				// 並んでいない要素やメンバーは0になる
+				g.memzero(local)
+				g.walk(ty.Exp)
+			default:
+				g.address(g.currentFn, local)
+				g.writer.Push(RAX) // 直近2つのRAXが必要な場合は前のRAXをスタックに退避
+				g.walk(ty.Exp)
+				g.writer.Pop(RDI)
+				g.store(local.Type)
+			}
 		}
 		// 戻り値はRAXに入っている
 	case *ast.InfixExp:
@@ -393,81 +840,208 @@ func (g *Generator) walk(node ast.Node) {
 			g.address(g.currentFn, infix.Left)
 			g.writer.Push(RAX) // 直近2つのRAXが必要な場合は前のRAXをスタックに退避
 			g.walk(infix.Right)
+			g.cast(infix.Right.Type(), infix.Left.Type())
 			g.writer.Pop(RDI)
-			g.writer.Mov(getReg(RAX, infix.Right.Type()), g.writer.Address(RDI))
+			g.store(infix.Left.Type())
+			return
+		}
+
+		if op := infix.BinaryOp(); op != "" {
+			g.assignOp(infix.Left, op, infix.Right)
+			return
+		}
+
+		if infix.Op == "&&" || infix.Op == "||" {
+			g.logical(infix)
 			return
 		}
 
+		// 比較は両辺を揃えた型で行い、結果はint
+		opTy := infix.Type()
+		switch infix.Op {
+		case "==", "!=", "<", "<=", ">", ">=":
+			opTy = operandType(infix.Left.Type(), infix.Right.Type())
+		}
+
 		g.walk(infix.Right) // 先に計算した方がRDIに入るから右辺を先にしないと-の時問題
+		g.cast(infix.Right.Type(), opTy)
 		g.writer.Push(RAX)
 		g.walk(infix.Left)
+		g.cast(infix.Left.Type(), opTy)
 		g.writer.Pop(RDI)
 
-		switch infix.Op {
-		case "+":
-			switch leftTy := infix.Left.Type().(type) {
-			case *types.Array:
-				// RDIにrightの数値の計算結果が入っている
-				unit := leftTy.Base.StackSize()
-				g.writer.Mul(fmt.Sprint(unit), RDI)
-				g.writer.Neg(RDI)
-			case *types.IntPointer:
-				// RDIにrightの数値の計算結果が入っている
-				unit := leftTy.Base.StackSize()
-				g.writer.Mul(fmt.Sprint(unit), RDI)
-				g.writer.Neg(RDI)
-			}
-			g.writer.Add(RDI, RAX)
-		case "-":
-			switch leftTy := infix.Left.Type().(type) {
-			case *types.Array:
-				// RDIにrightの数値の計算結果が入っている
-				unit := leftTy.Base.StackSize()
-				g.writer.Mul(fmt.Sprint(unit), RDI)
-				g.writer.Neg(RDI)
-			case *types.IntPointer:
-				// RDIにrightの数値の計算結果が入っている
-				unit := leftTy.Base.StackSize()
-				g.writer.Mul(fmt.Sprint(unit), RDI)
-				g.writer.Neg(RDI)
-			}
-			g.writer.Sub(RDI, RAX) // 右辺をRDIに入れているから
-		case "*":
-			g.writer.Mul(RDI, RAX)
-		case "/":
+		g.binop(infix.Op, infix.Left.Type(), opTy)
+	default:
+		g.fail(node.Token(), "Unknown node: %T, %s", node, node.String())
+	}
+}
+
+// assignOp generates left op= right. The address of left is evaluated
+// only once: a[i++] += 1 increments i once.
+func (g *Generator) assignOp(left ast.Exp, op string, right ast.Exp) {
+	// c += 1は(char)(c + 1)として計算する
+	ty := left.Type()
+	if types.IsInteger(ty) && types.IsInteger(right.Type()) {
+		ty = types.Common(ty, right.Type())
+	}
+
+	g.address(g.currentFn, left)
+	g.writer.Push(RAX) // 左辺のアドレスを退避
+	g.walk(right)
+	g.cast(right.Type(), ty)
+	g.writer.Mov(RAX, RDI)
+	g.writer.Mov(g.writer.Address(RSP), RAX)
+	g.load(left.Type())
+	g.cast(left.Type(), ty)
+	g.binop(op, left.Type(), ty)
+	g.cast(ty, left.Type())
+	g.writer.Pop(RDI)
+	g.writer.Mov(g.getReg(RAX, left.Type()), g.writer.Address(RDI))
+}
+
+// binop computes RAX op RDI into RAX. left is the type of the left
+// operand and ty the type both operands are converted to. Comparisons
+// of unsigned integers and pointers are unsigned.
+func (g *Generator) binop(op string, left, ty types.Type) {
+	unsigned := types.IsUnsigned(ty)
+	switch op {
+	case "+":
+		g.scale(left)
+		g.writer.Add(RDI, RAX)
+	case "-":
+		g.scale(left)
+		g.writer.Sub(RDI, RAX) // 右辺をRDIに入れているから
+	case "*":
+		g.writer.Mul(RDI, RAX)
+	case "/":
+		if unsigned {
+			g.writer.Udiv(RDI)
+		} else {
 			g.writer.Div(RDI)
-		case ">":
-			// swap RAX and RDI
-			g.writer.Push(RAX)
-			g.writer.Mov(RDI, RAX)
-			g.writer.Pop(RDI)
-			fallthrough
-		case "<":
-			g.writer.Cmp(RDI, RAX)
+		}
+	case "%":
+		if unsigned {
+			g.writer.Udiv(RDI)
+		} else {
+			g.writer.Div(RDI)
+		}
+		g.writer.Mov(RDX, RAX) // 余りはRDXに入る
+	case "&":
+		g.writer.And(RDI, RAX)
+	case "|":
+		g.writer.Or(RDI, RAX)
+	case "^":
+		g.writer.Xor(RDI, RAX)
+	case "<<":
+		g.writer.Mov(RDI, RCX) // シフト量はCLで渡す
+		g.writer.Sal(RAX)
+	case ">>":
+		// 拡張済みなので64bitのままシフトしてよい
+		g.writer.Mov(RDI, RCX)
+		if unsigned {
+			g.writer.Shr(RAX)
+		} else {
+			g.writer.Sar(RAX)
+		}
+	case "<":
+		g.writer.Cmp(RDI, RAX)
+		if unsigned {
+			g.writer.Setb(AL)
+		} else {
 			g.writer.Setl(AL)
-			g.writer.Movzb(AL, RAX)
-		case ">=":
-			g.writer.Push(RAX)
-			g.writer.Mov(RDI, RAX)
-			g.writer.Pop(RDI)
-			fallthrough
-		case "<=":
-			g.writer.Cmp(RDI, RAX)
+		}
+		g.writer.Movzb(AL, RAX)
+	case "<=":
+		g.writer.Cmp(RDI, RAX)
+		if unsigned {
+			g.writer.Setbe(AL)
+		} else {
 			g.writer.Setle(AL)
-			g.writer.Movzb(AL, RAX)
-		case "==":
-			g.writer.Cmp(RDI, RAX)
-			g.writer.Sete(AL)
-			g.writer.Movzb(AL, RAX)
-		case "!=":
-			g.writer.Cmp(RDI, RAX)
-			g.writer.Setne(AL)
-			g.writer.Movzb(AL, RAX)
 		}
+		g.writer.Movzb(AL, RAX)
+	case ">":
+		g.writer.Cmp(RDI, RAX)
+		if unsigned {
+			g.writer.Seta(AL)
+		} else {
+			g.writer.Setg(AL)
+		}
+		g.writer.Movzb(AL, RAX)
+	case ">=":
+		g.writer.Cmp(RDI, RAX)
+		if unsigned {
+			g.writer.Setae(AL)
+		} else {
+			g.writer.Setge(AL)
+		}
+		g.writer.Movzb(AL, RAX)
+	case "==":
+		g.writer.Cmp(RDI, RAX)
+		g.writer.Sete(AL)
+		g.writer.Movzb(AL, RAX)
+	case "!=":
+		g.writer.Cmp(RDI, RAX)
+		g.writer.Setne(AL)
+		g.writer.Movzb(AL, RAX)
+	}
+
+	// 溢れた分を切り捨てて拡張し直す
+	switch op {
+	case "+", "-", "*", "<<":
+		g.extend(ty)
+	}
+}
+
+// operandType returns the type that both operands of a comparison are
+// converted to. Pointers compare as unsigned long.
+func operandType(left, right types.Type) types.Type {
+	if types.IsInteger(left) && types.IsInteger(right) {
+		return types.Common(left, right)
+	}
+	return types.GetULong()
+}
+
+// scale multiplies the integer in RDI by the size of the element that
+// ty points to, so that p + 1 is the next element.
+func (g *Generator) scale(ty types.Type) {
+	var unit int
+	switch ty := ty.(type) {
+	case *types.Array:
+		unit = ty.Base.StackSize()
+	case *types.IntPointer:
+		unit = ty.Base.StackSize()
 	default:
-		fmt.Fprintf(os.Stderr, "Unknown node: %T, %s\n", node, node.String())
-		os.Exit(1)
+		return
+	}
+
+	g.writer.Mul(fmt.Sprint(unit), RDI)
+}
+
+// logical evaluates && and || to 0 or 1. The right is skipped once the
+// left decides the result.
+func (g *Generator) logical(infix *ast.InfixExp) {
+	lblShort := g.genLbl()
+	lblEnd := g.genLbl()
+
+	// &&は偽で、||は真で打ち切る
+	jmp := g.writer.Je
+	short, other := "0", "1"
+	if infix.Op == "||" {
+		jmp = g.writer.Jne
+		short, other = "1", "0"
 	}
+
+	g.walk(infix.Left)
+	g.writer.Cmp("0", RAX)
+	jmp(lblShort)
+	g.walk(infix.Right)
+	g.writer.Cmp("0", RAX)
+	jmp(lblShort)
+	g.writer.Mov(other, RAX)
+	g.writer.Jmp(lblEnd)
+	g.writer.Label(lblShort)
+	g.writer.Mov(short, RAX)
+	g.writer.Label(lblEnd)
 }
 
 func (g *Generator) prolog() {
@@ -482,118 +1056,199 @@ func (g *Generator) epilog() {
 	g.writer.Ret()
 }
 
-func (g *Generator) genLbl() string {
-	s := fmt.Sprintf(".L%d", g.lblCnt)
-	g.lblCnt++
-	return s
+// loopBody generates the body of a loop with the targets of break and
+// continue in it.
+func (g *Generator) loopBody(body ast.Stmt, lblBreak, lblContinue string) {
+	g.breaks = append(g.breaks, lblBreak)
+	g.continues = append(g.continues, lblContinue)
+	g.walk(body)
+	g.breaks = g.breaks[:len(g.breaks)-1]
+	g.continues = g.continues[:len(g.continues)-1]
 }
 
-func (g *Generator) getOffset(fn *ast.FuncDefNode, node interface{}) (string, string) {
-	var name string
-	var ty types.Type
+// caseが一定数以上あり、値が密に並んでいれば飛び先の表を使う
+const (
+	jumpTableMinCases = 4
+	jumpTableDensity  = 3 // 表の大きさはcaseの数のこの倍まで
+)
 
-	switch node := node.(type) {
-	case *ast.LocalVariable:
-		name = node.Name
-		ty = node.Type
-	case *ast.IdentExp:
-		name = node.Name
-		ty = node.Type()
-	default:
-		err("Invalid node: %T\n", node)
-		os.Exit(1)
+// switchStmt jumps to the case matching the condition, through a jump
+// table in .rodata if the case values are dense or by comparing them
+// one by one otherwise.
+func (g *Generator) switchStmt(stmt *ast.SwitchStmt) {
+	ty := types.Promote(stmt.Cond.Type())
+	lblEnd := g.genLbl()
+	lblDefault := lblEnd
+	if stmt.Default != nil {
+		lblDefault = g.genLbl()
+		g.caseLbls[stmt.Default] = lblDefault
 	}
 
-	// local
-	offset, ok := fn.Offsets[name]
-	if ok {
-		return fmt.Sprintf("-%d", offset), RBP
+	// Duplicate values have been reported by the parser.
+	vals := make([]int, len(stmt.Cases))
+	for i, c := range stmt.Cases {
+		vals[i] = g.caseValue(c, ty)
+		g.caseLbls[c] = g.genLbl()
 	}
 
-	// global
-	_, ok = g.globals()[name]
-	if ok {
-		return name, RIP
+	g.walk(stmt.Cond)
+	reg := g.getReg(RAX, ty)
+	if min, max, ok := denseRange(vals); ok {
+		g.jumpTable(stmt, ty, min, max, lblDefault)
+	} else {
+		for i, c := range stmt.Cases {
+			if val := fmt.Sprint(vals[i]); ty.Size() == 4 || isImm32(vals[i]) {
+				g.writer.Cmp(val, reg)
+			} else {
+				// 32bitに収まらない値は直接比較できない
+				g.writer.Mov(val, RDI)
+				g.writer.Cmp(RDI, reg)
+			}
+			g.writer.Je(g.caseLbls[c])
+		}
+		g.writer.Jmp(lblDefault)
 	}
 
-	err("Invalid ident name: '%s' of type '%s'\n", name, ty)
-	os.Exit(1)
-	return "", RBP
+	g.breaks = append(g.breaks, lblEnd)
+	g.walk(stmt.Body)
+	g.breaks = g.breaks[:len(g.breaks)-1]
+	g.writer.Label(lblEnd)
 }
 
-// FIXME: Dereference is not supported. i.e. int *x = &y
-func (g *Generator) eval(exp ast.Exp) ast.Exp {
-	debug("eval %T, %s", exp, exp)
-	switch exp := exp.(type) {
-	case *ast.NumExp:
-		return exp
-	case *ast.StringLiteralExp:
-		return exp
-	case *ast.ArrayLiteral:
-		return exp
-	case *ast.UnaryExp:
-		if exp.Op == "&" {
-			_, ok := exp.Right.(*ast.IdentExp)
-			if !ok {
-				g.Error(exp.Right.Token(), "Invalid exp for rvalue of & unary exp: %s", exp.Right)
+// jumpTable jumps to the case of the value in RAX through a table of
+// label offsets indexed by value - min.
+func (g *Generator) jumpTable(stmt *ast.SwitchStmt, ty types.Type, min, max int, lblDefault string) {
+	reg, rdi := g.getReg(RAX, ty), g.getReg(RDI, ty)
+	g.writer.Mov(fmt.Sprint(min), rdi)
+	g.writer.Sub(rdi, reg)
+	g.writer.Mov(fmt.Sprint(max-min), rdi)
+	g.writer.Cmp(rdi, reg)
+	g.writer.Ja(lblDefault) // 符号なしで比べるので範囲外は負の数も含めてdefaultへ
+
+	// 表には表からの相対位置を置く。絶対アドレスだとPIEで再配置が要る
+	lblTable := g.genLbl()
+	g.writer.Lea(lblTable, RIP, RDI)
+	g.writer.Movsx("DWORD PTR "+g.writer.Address(g.writer.Index(RDI, RAX, 4)), RAX)
+	g.writer.Add(RDI, RAX)
+	g.writer.JmpIndirect(RAX)
+
+	lbls := make([]string, max-min+1)
+	for i := range lbls {
+		lbls[i] = lblDefault
+	}
+	for _, c := range stmt.Cases {
+		lbls[g.caseValue(c, ty)-min] = g.caseLbls[c]
+	}
+
+	g.writer.Rodata()
+	g.writer.Text(".align 4")
+	g.writer.Label(lblTable)
+	for _, lbl := range lbls {
+		g.writer.Text(fmt.Sprintf(".long %s-%s", lbl, lblTable))
+	}
+	g.writer.Text(".text")
+}
+
+// caseValue evaluates the constant of c converted to ty, the promoted
+// type of the switch condition.
+func (g *Generator) caseValue(c *ast.CaseStmt, ty types.Type) (val int) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(*diag.Diagnostic); !ok {
+				panic(r)
 			}
-			return exp
+			g.fail(c.Exp.Token(), "Case label does not reduce to an integer constant: %s", c.Exp)
 		}
+	}()
 
-		right := g.eval(exp.Right)
-		r, ok := right.(*ast.NumExp)
-		if !ok {
-			g.Error(right.Token(), "Invalid right for unary exp: %s", right)
-		}
+	// evalの失敗はcaseの値が定数でないことによる
+	num, ok := g.eval(c.Exp).(*ast.NumExp)
+	if !ok {
+		g.fail(c.Exp.Token(), "Case label does not reduce to an integer constant: %s", c.Exp)
+	}
 
-		if exp.Op == "+" {
-			return r
-		}
+	return types.Convert(num.Val, ty)
+}
 
-		if exp.Op == "-" {
-			return &ast.NumExp{Val: -r.Val}
-		}
+// denseRange returns the smallest and the largest value if a jump table
+// fits them.
+func denseRange(vals []int) (int, int, bool) {
+	if len(vals) < jumpTableMinCases {
+		return 0, 0, false
+	}
 
-		g.Error(exp.Token(), "Invalid operator for global rvalue unary right: %s", exp.Op)
-	case *ast.InfixExp:
-		debug("eval %T, %s", exp, exp)
-		left := g.eval(exp.Left)
-		right := g.eval(exp.Right)
-		l, ok := left.(*ast.NumExp)
-		if !ok {
-			g.Error(left.Token(), "Invalid left exp: %s", left)
+	min, max := vals[0], vals[0]
+	for _, val := range vals {
+		if val < min {
+			min = val
 		}
-
-		r, ok := right.(*ast.NumExp)
-		if !ok {
-			g.Error(right.Token(), "Invalid right exp: %s", right)
+		if val > max {
+			max = val
 		}
+	}
 
-		if exp.Op == "+" {
-			val := l.Val + r.Val
-			return &ast.NumExp{Val: val}
-		}
+	// max-minが溢れてもuint64なら正しい差になる
+	if uint64(max-min) >= uint64(jumpTableDensity*len(vals)) {
+		return 0, 0, false
+	}
+	return min, max, true
+}
 
-		if exp.Op == "-" {
-			val := l.Val - r.Val
-			return &ast.NumExp{Val: val}
-		}
+func isImm32(val int) bool {
+	return val >= math.MinInt32 && val <= math.MaxInt32
+}
 
-		if exp.Op == "*" {
-			val := l.Val * r.Val
-			return &ast.NumExp{Val: val}
-		}
+// userLbl returns the assembly label of a label in the current function.
+func (g *Generator) userLbl(name string) string {
+	return fmt.Sprintf(".L.label.%s.%s", g.currentFn.Name, name)
+}
 
-		if exp.Op == "/" {
-			val := l.Val / r.Val
-			return &ast.NumExp{Val: val}
-		}
+func (g *Generator) genLbl() string {
+	s := fmt.Sprintf(".L%d", g.lblCnt)
+	g.lblCnt++
+	return s
+}
 
-		g.Error(exp.Token(), "Invalid operator for global rvalue: %s", exp.Op)
+func (g *Generator) getOffset(fn *ast.FuncDefNode, node interface{}) (string, string) {
+	var local *ast.LocalVariable
+
+	switch node := node.(type) {
+	case *ast.LocalVariable:
+		local = node
+	case *ast.IdentExp:
+		// 同じ名前でもスコープによって別の変数を指す
+		local = node.Var
 	}
 
-	g.Error(exp.Token(), "Invalid exp for global rvalue: %s", exp)
-	return nil
+	if local == nil {
+		g.fail(tokenOf(node), "Invalid node: %T", node)
+	}
+
+	// local
+	if local.IsLocal {
+		// 先に宣言した変数ほど低いアドレスに置く。&x + 1は次の変数を指す
+		return fmt.Sprintf("%d", local.Offset-local.Type.StackSize()-fn.StackSize), RBP
+	}
+
+	// global
+	if _, ok := g.globals()[local.Name]; ok {
+		return local.Name, RIP
+	}
+
+	g.fail(tokenOf(node), "Invalid ident name: '%s' of type '%s'", local.Name, local.Type)
+	return "", RBP
+}
+
+// eval reduces a constant expression to a NumExp. Address constants
+// are computed by constAddr.
+func (g *Generator) eval(exp ast.Exp) ast.Exp {
+	debug("eval %T, %s", exp, exp)
+	num, err := ast.Eval(exp)
+	if err != nil {
+		e := err.(*ast.TypeError)
+		g.fail(e.Token(), "%s", e.Error())
+	}
+	return num
 }
 
 func reduceSizeof(unary *ast.UnaryExp) int {
@@ -610,7 +1265,8 @@ func err(s string, args ...interface{}) {
 	fmt.Fprintf(os.Stderr, s+"\n", args...)
 }
 
-func getReg(reg string, ty types.Type) string {
+// getReg returns the part of reg for a value of ty.
+func (g *Generator) getReg(reg string, ty types.Type) string {
 	switch ty.(type) {
 	case *types.Char:
 		r, ok := BYTE[reg]
@@ -618,12 +1274,20 @@ func getReg(reg string, ty types.Type) string {
 			goto ERROR
 		}
 		return r
+	case *types.Short:
+		r, ok := WORD[reg]
+		if !ok {
+			goto ERROR
+		}
+		return r
 	case *types.Int:
 		r, ok := DWORD[reg]
 		if !ok {
 			goto ERROR
 		}
 		return r
+	case *types.Long:
+		return reg
 	case *types.Array:
 		return reg
 	case *types.IntPointer:
@@ -633,8 +1297,7 @@ func getReg(reg string, ty types.Type) string {
 	}
 
 ERROR:
-	err("Invalid size %T for %s", ty, reg)
-	os.Exit(1)
+	g.fail(g.tkn, "Invalid size %T for %s", ty, reg)
 	return ""
 }
 
@@ -642,6 +1305,8 @@ func getType(size int) string {
 	switch size {
 	case 1:
 		return ".byte"
+	case 2:
+		return ".short"
 	case 4:
 		return ".long"
 	case 8:
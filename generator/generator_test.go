@@ -2,8 +2,11 @@ package generator
 
 import (
 	"bytes"
+	"go9cc/ast"
+	"go9cc/diag"
 	"go9cc/parser"
 	"go9cc/token"
+	"go9cc/types"
 	"testing"
 )
 
@@ -11,14 +14,55 @@ func TestGenerator(t *testing.T) {
 	tests := []struct {
 		input string
 		want  string
-	}{}
+	}{
+		{
+			"int a[3] = {1};\nchar s[] = \"ab\";\nint *p = &a[0] + 1;\nint main() { return a[1]; }\n",
+			`.intel_syntax noprefix
+  .data
+  .size, 3
+.L.string.0:
+  .string "ab"
+  .text
+  .globl a
+  .data
+a:
+  .long 1
+  .zero 8
+  .globl s
+  .data
+s:
+  .string "ab"
+  .globl p
+  .data
+p:
+  .quad a+4
+  .text
+  .globl main
+main:
+  push rbp
+  mov rbp, rsp
+  sub rsp, 0
+  mov rax, 1
+  lea rdi, a[rip]
+  lea rax, [rdi+rax*4]
+  movsxd rax, DWORD PTR [rax]
+  jmp .L.return.main
+.L.return.main:
+  mov rsp, rbp
+  pop rbp
+  ret
+`,
+		},
+	}
 
 	for i, tt := range tests {
 		out := bytes.NewBufferString("")
 		tzer := token.New(tt.input)
 		p := parser.New(tzer)
 		g := New(p, out)
-		g.Gen()
+		if err := g.Gen(); err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
 
 		if out.String() != tt.want {
 			t.Fatalf(
@@ -30,3 +74,72 @@ func TestGenerator(t *testing.T) {
 		}
 	}
 }
+
+func TestGeneratorError(t *testing.T) {
+	tests := []struct {
+		input string
+		msg   string
+	}{
+		{"int main() { int a; switch (1) { case a: return 0; } }", "Case label does not reduce to an integer constant: a"},
+	}
+
+	for i, tt := range tests {
+		tzer := token.New(tt.input)
+		p := parser.New(tzer)
+		g := New(p, bytes.NewBufferString(""))
+		err := g.Gen()
+		d, ok := err.(*diag.Diagnostic)
+		if !ok {
+			t.Fatalf("%d: *diag.Diagnostic expected, but got=%T (%v)", i, err, err)
+		}
+
+		if d.Message != tt.msg {
+			t.Errorf("%d: wrong msg: got=%s, want=%s", i, d.Message, tt.msg)
+		}
+
+		if !d.Pos.IsValid() {
+			t.Errorf("%d: the error has no position: %s", i, d)
+		}
+	}
+}
+
+// Errors which the parser should have caught are still located.
+func TestInternalErrorPosition(t *testing.T) {
+	tzer := token.New("int main() { return 3; }")
+	head, err := tzer.Tokenize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	num := head
+	for num.Kind != token.NUM {
+		num = num.Next
+	}
+
+	g := New(parser.New(tzer), bytes.NewBufferString(""))
+	tests := []func(){
+		func() { g.address(nil, ast.NewNumExp(3, num)) },
+		func() { g.getOffset(nil, ast.NewNumExp(3, num)) },
+		func() {
+			g.tkn = num
+			g.getReg(RAX, types.GetVoid())
+		},
+	}
+
+	for i, tt := range tests {
+		err := func() (err error) {
+			defer func() { err = g.catch(recover()) }()
+			tt()
+			return nil
+		}()
+
+		d, ok := err.(*diag.Diagnostic)
+		if !ok {
+			t.Fatalf("%d: *diag.Diagnostic expected, but got=%T (%v)", i, err, err)
+		}
+
+		if d.Pos.Line() != 1 || d.Pos.Column() != 21 {
+			t.Errorf("%d: want the position of 3, but got=%s", i, d)
+		}
+	}
+}
@@ -1,41 +1,257 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"go9cc/diag"
+	"go9cc/driver"
 	"go9cc/generator"
 	"go9cc/parser"
 	"go9cc/token"
+	"io"
 	"io/ioutil"
-	"log"
 	"os"
+	"strings"
 )
 
+// Exit status
+const (
+	exitOK = iota
+	exitCompile
+	exitUsage
+	exitIO
+	exitTool
+)
+
+const usage = `Usage: gocc [options] file...
+
+Compiles C files into an executable. "-" reads a C file from stdin.
+Object files (.o) and archives (.a) are passed to the linker.
+
+Exit status is 0 on success, 1 on compile errors, 2 on usage errors,
+3 on I/O errors and 4 when the assembler or the linker fails.
+
+Options:
+`
+
+type options struct {
+	output     string
+	asm        bool // -S
+	obj        bool // -c
+	preprocess bool // -E
+	format     string
+	errorLimit int
+	debug      bool
+}
+
 func main() {
-	if len(os.Args) < 2 {
-		log.Println("Num of args must be more than 2.")
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	opts := &options{}
+	fs := flag.NewFlagSet("gocc", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opts.output, "o", "", "write the output to `file`; - is stdout")
+	fs.BoolVar(&opts.asm, "S", false, "compile only; write assembly")
+	fs.BoolVar(&opts.obj, "c", false, "compile and assemble; write object files")
+	fs.BoolVar(&opts.preprocess, "E", false, "preprocess only; write to stdout")
+	fs.StringVar(&opts.format, "fdiagnostics-format", "caret", "diagnostics `format`: caret or json")
+	fs.IntVar(&opts.errorLimit, "ferror-limit", parser.DefaultErrorLimit, "stop after `n` errors; 0 is no limit")
+	fs.BoolVar(&opts.debug, "debug", false, "trace the parser and the generator on stderr")
+
+	inputs, err := parseArgs(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+
+	renderer, err := diag.NewRenderer(opts.format)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
 	}
-	arg := os.Args[1]
 
-	var data string
+	parser.DEBUG = opts.debug
+	generator.DEBUG = opts.debug
 
-	if arg == "-c" {
-		data = os.Args[2]
-	} else {
-		file, err := os.Open(arg)
+	files := []*diag.File{}
+	objs := []string{}
+	for _, input := range inputs {
+		if strings.HasSuffix(input, ".o") || strings.HasSuffix(input, ".a") {
+			objs = append(objs, input)
+			continue
+		}
+
+		file, err := readFile(input, stdin)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "File %s not found.\n", err.Error())
+			fmt.Fprintf(stderr, "Cannot read from file %s.\n", err.Error())
+			return exitIO
 		}
-		defer file.Close()
+		files = append(files, file)
+	}
 
-		dat, err := ioutil.ReadAll(file)
+	stopEarly := opts.preprocess || opts.asm || opts.obj
+	if len(files) == 0 && (stopEarly || len(objs) == 0) {
+		fmt.Fprintln(stderr, "No input files.")
+		fs.Usage()
+		return exitUsage
+	}
+
+	if stopEarly && len(files) > 1 && opts.output != "" && !opts.preprocess {
+		fmt.Fprintln(stderr, "Cannot specify -o with -S or -c and multiple files.")
+		return exitUsage
+	}
+
+	if stopEarly && len(objs) > 0 {
+		fmt.Fprintf(stderr, "Linker inputs unused because linking not done: %s\n", strings.Join(objs, " "))
+	}
+
+	if opts.preprocess {
+		out, err := create(opts.output, stdout)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Cannot read from file %s.\n", err.Error())
+			fmt.Fprintln(stderr, err)
+			return exitIO
 		}
-		data = string(dat)
+		defer out.Close()
+
+		for _, file := range files {
+			io.WriteString(out, token.StripComments(string(file.Code)))
+		}
+		return exitOK
+	}
+
+	d := driver.New()
+	d.ErrorLimit = opts.errorLimit
+	units, err := d.Compile(files)
+	if err != nil {
+		renderer.Render(stderr, diag.AsList(err))
+		return exitCompile
 	}
 
-	tzer := token.New(data)
-	parser := parser.New(tzer)
-	gen := generator.New(parser, os.Stdout)
-	gen.Gen()
+	warnings := diag.List{}
+	for _, unit := range units {
+		warnings = append(warnings, unit.Warnings...)
+	}
+	if len(warnings) > 0 {
+		renderer.Render(stderr, warnings)
+	}
+
+	switch {
+	case opts.asm:
+		for _, unit := range units {
+			out, err := create(outputName(opts.output, unit, ".s"), stdout)
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				return exitIO
+			}
+
+			_, err = out.Write(unit.Asm.Bytes())
+			out.Close()
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				return exitIO
+			}
+		}
+	case opts.obj:
+		names := []string{}
+		for _, unit := range units {
+			names = append(names, outputName(opts.output, unit, ".o"))
+		}
+
+		if err := d.AssembleAll(units, names); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitTool
+		}
+	default:
+		if err := driver.CheckSymbols(units); err != nil {
+			renderer.Render(stderr, diag.AsList(err))
+			return exitCompile
+		}
+
+		output := opts.output
+		if output == "" {
+			output = "a.out"
+		}
+
+		if err := d.Link(units, objs, output); err != nil {
+			fmt.Fprintln(stderr, err)
+			if _, ok := err.(*driver.ToolError); ok {
+				return exitTool
+			}
+			return exitIO
+		}
+	}
+
+	return exitOK
+}
+
+// parseArgs parses the flags and returns the other arguments. Unlike
+// FlagSet.Parse, flags may follow the input files: gocc a.c -o a
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	inputs := []string{}
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		args = fs.Args()
+		if len(args) == 0 {
+			return inputs, nil
+		}
+
+		inputs = append(inputs, args[0])
+		args = args[1:]
+	}
+}
+
+func readFile(path string, stdin io.Reader) (*diag.File, error) {
+	if path == "-" {
+		dat, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			return nil, err
+		}
+		return diag.NewFile("<stdin>", string(dat)), nil
+	}
+
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return diag.NewFile(path, string(dat)), nil
+}
+
+// outputName returns -o if given, otherwise the input file name with ext
+// like cc does. The assembly of stdin goes to stdout.
+func outputName(output string, unit *driver.Unit, ext string) string {
+	if output != "" {
+		return output
+	}
+
+	if unit.File.Name == "<stdin>" {
+		if ext == ".s" {
+			return "-"
+		}
+		return "stdin" + ext
+	}
+	return driver.BaseName(unit.File.Name) + ext
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// create opens the output file. "" and "-" are stdout.
+func create(path string, stdout io.Writer) (io.WriteCloser, error) {
+	if path == "" || path == "-" {
+		return nopCloser{stdout}, nil
+	}
+	return os.Create(path)
 }
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+const (
+	okSrc  = "// comment\nint main() { return 0; }\n"
+	badSrc = "int main() { return x; }\n"
+)
+
+// inTempDir runs the test in a temporary directory holding ok.c, ok2.c,
+// bad.c and bad.o, where the outputs are written.
+func inTempDir(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	files := map[string]string{
+		"ok.c":  okSrc,
+		"ok2.c": okSrc,
+		"bad.c": badSrc,
+		"bad.o": "not an object file",
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(name, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRunExitStatus(t *testing.T) {
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{[]string{"ok.c"}, exitOK},
+		{[]string{"ok.c", "-o", "ok"}, exitOK},
+		{[]string{"-S", "bad.c"}, exitCompile},
+		{[]string{"bad.c", "ok.c"}, exitCompile},
+		{[]string{}, exitUsage},
+		{[]string{"-S"}, exitUsage},
+		{[]string{"-x", "ok.c"}, exitUsage},
+		{[]string{"-fdiagnostics-format", "xml", "ok.c"}, exitUsage},
+		{[]string{"-S", "-o", "out.s", "ok.c", "ok2.c"}, exitUsage},
+		{[]string{"-c", "-o", "out.o", "ok.c", "ok2.c"}, exitUsage},
+		{[]string{"-S", "missing.c"}, exitIO},
+		{[]string{"ok.c", "bad.o"}, exitTool},
+	}
+
+	inTempDir(t)
+	for i, tt := range tests {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		got := run(tt.args, strings.NewReader(""), stdout, stderr)
+		if got != tt.want {
+			t.Errorf("%d: %v: exit status %d expected, but got=%d\n%s", i, tt.args, tt.want, got, stderr)
+		}
+	}
+}
+
+func TestRunOutput(t *testing.T) {
+	tests := []struct {
+		args   []string
+		files  []string // written files
+		stdout string   // a part of stdout, "" if nothing is written
+	}{
+		{[]string{"ok.c"}, []string{"a.out"}, ""},
+		{[]string{"ok.c", "-o", "prog"}, []string{"prog"}, ""},
+		{[]string{"-S", "ok.c", "ok2.c"}, []string{"ok.s", "ok2.s"}, ""},
+		{[]string{"-S", "-o", "out.s", "ok.c"}, []string{"out.s"}, ""},
+		{[]string{"-S", "-o", "-", "ok.c"}, []string{}, "main:"},
+		{[]string{"-S", "-"}, []string{}, "main:"},
+		{[]string{"-c", "ok.c", "ok2.c"}, []string{"ok.o", "ok2.o"}, ""},
+		{[]string{"-c", "-o", "out.o", "ok.c"}, []string{"out.o"}, ""},
+		{[]string{"-c", "-"}, []string{"stdin.o"}, ""},
+		{[]string{"-E", "ok.c"}, []string{}, "int main() { return 0; }"},
+		{[]string{"-E", "-"}, []string{}, "int main() { return 0; }"},
+		{[]string{"-E", "-o", "out.i", "ok.c"}, []string{"out.i"}, ""},
+	}
+
+	for i, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			inTempDir(t)
+			stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+			if got := run(tt.args, strings.NewReader(okSrc), stdout, stderr); got != exitOK {
+				t.Fatalf("%d: exit status %d expected, but got=%d\n%s", i, exitOK, got, stderr)
+			}
+
+			for _, name := range tt.files {
+				if _, err := os.Stat(name); err != nil {
+					t.Errorf("%d: %s must be written: %s", i, name, err)
+				}
+			}
+
+			if tt.stdout == "" && stdout.Len() > 0 {
+				t.Errorf("%d: nothing must be written to stdout, but got=%q", i, stdout)
+			}
+			if !strings.Contains(stdout.String(), tt.stdout) {
+				t.Errorf("%d: stdout must contain %q, but got=%q", i, tt.stdout, stdout)
+			}
+			if strings.Contains(stdout.String(), "// comment") {
+				t.Errorf("%d: comments must be stripped, but got=%q", i, stdout)
+			}
+		})
+	}
+}
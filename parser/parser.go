@@ -3,45 +3,67 @@ package parser
 import (
 	"fmt"
 	"go9cc/ast"
+	"go9cc/diag"
 	"go9cc/token"
 	"go9cc/types"
+	"math"
 	"os"
-	"strconv"
+	"sort"
+	"strings"
 )
 
-const DEBUG = true
+// DEBUG traces the parse on stderr.
+var DEBUG = false
 
 /*
-program     = (funcdef | global)*
+program     = (funcdef | prototype | global)*
 global      = declaration
 funcdef     = declspec declarator funcargs blockStmt
-funcargs    = "(" declspec declarator ("," declspec declarator)* ")" | "(" ")"
+prototype   = declspec declarator funcargs ";"
+funcargs    = "(" param ("," param)* ("," "...")? ")" | "(" "void"? ")"
+param       = declspec declarator
 blockstmt   = "{" stmt* "}"
-stmt        = (declaration ";") | (return expr ";") | (expr ";") | ifstmt | whilestmt | blockstmt
+stmt        = (declaration ";") | (return expr ";") | (expr ";") | ifstmt | whilestmt | dowhilestmt | blockstmt
+            | ("break" ";") | ("continue" ";") | ("goto" ident ";") | (ident ":" stmt)
+            | switchstmt | ("case" conditional ":" stmt) | ("default" ":" stmt)
 forstmt     = "for" "(" (expr|declaration)? ";" expr? ";" expr? ")" stmt
 ifstmt      = "if" "(" expr ")" stmt ("else" stmt)?
 whilestmt   = "while" "(" expr ")" stmt
-expr        = assign
-assign      = eq ("=" assign)?
+dowhilestmt = "do" stmt "while" "(" expr ")" ";"
+switchstmt  = "switch" "(" expr ")" stmt
+expr        = assign ("," assign)*
+assign      = conditional (("=" | "+=" | "-=" | "*=" | "/=" | "%=" | "&=" | "|=" | "^=" | "<<=" | ">>=") assign)?
+conditional = logor ("?" expr ":" conditional)?
+logor       = logand ("||" logand)*
+logand      = bitor ("&&" bitor)*
+bitor       = bitxor ("|" bitxor)*
+bitxor      = bitand ("^" bitand)*
+bitand      = eq ("&" eq)*
 eq          = lg ("==" lg)?
-lg          = add ("<" add)?
+lg          = shift ("<" shift)?
+shift       = add ("<<" add | ">>" add)*
 add         = mul ("+" mul | "-" mul)*
-mul         = unary ("*" unary | "/" unary)*
-unary       = ("+" | "-" | "sizeof")? primary
+mul         = unary ("*" unary | "/" unary | "%" unary)*
+unary       = ("+" | "-" | "*" | "&" | "!" | "~" | "++" | "--" | "sizeof")? postfix | "sizeof" "(" typename ")"
+postfix     = primary ("[" expr "]" | "." ident | "->" ident | "++" | "--")*
 primary     = (ident "[" expr "]") | string | num | funccall | ident | "(" expr ")"
 funccall    = ident funcparams
-funcparams  = "(" ( expr ("," expr)* ")" | ")")
-arrayliteral = "{" "}" | "{" expr ("," expr)* "}"
+funcparams  = "(" ( assign ("," assign)* ")" | ")")
+arrayliteral = "{" "}" | "{" assign ("," assign)* "}"
 
 declaration =
-  declspec
+  "typedef"? declspec
     (declarator
-      ("=" expr)?
-      ("," declarator ("=" (expr | arrayliteral))?)
+      ("=" assign)?
+      ("," declarator ("=" (assign | arrayliteral))?)
     *)?
   ";"
-declarator = "*"* ident ("[" num "]")?
-declspec = "int"
+declarator = "*"* ident ("[" conditional "]")?
+typename = declspec "*"* ("[" conditional "]")?
+declspec = ("void" | "char" | "short" | "int" | "long" | "signed" | "unsigned")+ | structdecl | enumdecl | typedefname
+structdecl = ("struct" | "union") ident? ("{" (declspec declarator ("," declarator)* ";")* "}")?
+enumdecl = "enum" ident? ("{" enumerator ("," enumerator)* ","? "}")?
+enumerator = ident ("=" conditional)?
 */
 
 /* Parser */
@@ -51,33 +73,263 @@ type Parser struct {
 	head, cur *token.Token
 	curFn     *ast.FuncDefNode
 	Globals   map[string]*ast.LocalVariable
-	funcdefs  map[string]*ast.FuncDefNode
+	funcdefs  map[string]*ast.FuncDefNode // definitions, or prototypes until defined
+	implicit  map[string]*token.Token     // functions called without a declaration
 	Strings   []*ast.StringLiteralExp
 	strCnt    int
+	errors    diag.List // errors and warnings in the order they were reported
+	errorCnt  int
+
+	// Jump targets in the function being parsed.
+	breakDepth    int
+	continueDepth int
+	labels        map[string]*token.Token
+	gotos         []*ast.GotoStmt
+	switches      []*ast.SwitchStmt
+	caseVals      []map[int]*ast.CaseStmt // case values of each of switches
+
+	// Innermost block scope. nil at file scope.
+	scope *scope
+	tags  map[string]*tag // struct, union and enum tags at file scope
+
+	// ErrorLimit stops parsing after that many errors. 0 means no limit.
+	ErrorLimit int
 }
 
+const DefaultErrorLimit = 20
+
+// errorLimitReached unwinds the whole parse once ErrorLimit is hit.
+type errorLimitReached struct{}
+
 func New(tzer *token.Tokenizer) *Parser {
 	parser := &Parser{
-		tzer:     tzer,
-		Globals:  map[string]*ast.LocalVariable{},
-		funcdefs: map[string]*ast.FuncDefNode{},
-		Strings:  []*ast.StringLiteralExp{},
+		tzer:       tzer,
+		Globals:    map[string]*ast.LocalVariable{},
+		funcdefs:   map[string]*ast.FuncDefNode{},
+		implicit:   map[string]*token.Token{},
+		tags:       map[string]*tag{},
+		Strings:    []*ast.StringLiteralExp{},
+		ErrorLimit: DefaultErrorLimit,
 	}
-	parser.head = parser.tzer.Tokenize()
-	parser.cur = parser.head
 	return parser
 }
 
-func (p *Parser) Parse() *ast.ProgramNode {
-	node := p.program()
+// Parse parses the whole program. Errors do not stop parsing: each broken
+// statement or declaration is reported and skipped, and all errors are
+// returned together as a diag.List.
+func (p *Parser) Parse() (node *ast.ProgramNode, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(errorLimitReached); ok {
+				msg := "too many errors emitted, stopping now [-ferror-limit=%d]"
+				d := diag.Errorf(diag.Pos{}, "", msg, p.ErrorLimit)
+				d.Severity = diag.Note
+				p.errors = append(p.errors, d)
+			} else {
+				p.errors = append(p.errors, p.catch(r))
+			}
+			node, err = nil, p.errors
+		}
+	}()
+
+	p.head, err = p.tzer.Tokenize()
+	if err != nil {
+		return nil, diag.AsList(err)
+	}
+	p.cur = p.head
+
+	node = p.program()
+	if err := p.errors.Err(); err != nil {
+		return nil, err
+	}
+
 	for _, funcdef := range node.FuncDefs {
 		funcdef.PrepareStackSize()
 	}
-	return node
+	return node, nil
+}
+
+// Error returns a compile error located at token.
+func (p *Parser) Error(token *token.Token, msg string, args ...interface{}) *diag.Diagnostic {
+	return p.tzer.Error(token, msg, args...)
+}
+
+// fail aborts parsing with a compile error located at token.
+// Parse recovers it and returns it to the caller.
+func (p *Parser) fail(token *token.Token, msg string, args ...interface{}) {
+	panic(p.Error(token, msg, args...))
+}
+
+// check aborts parsing with err, the result of a CheckTypeError.
+func (p *Parser) check(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+// catch turns a value recovered from an aborted parse into an error.
+// Anything else than a compile error is a bug and keeps panicking.
+func (p *Parser) catch(r interface{}) *diag.Diagnostic {
+	switch r := r.(type) {
+	case *diag.Diagnostic:
+		return r
+	case *ast.TypeError:
+		d := p.Error(r.Token(), "%s", r.Error())
+		d.Code = diag.Type
+		return d
+	}
+
+	panic(r)
+}
+
+// report records a compile error and lets parsing go on.
+func (p *Parser) report(d *diag.Diagnostic) {
+	p.errors = append(p.errors, d)
+	p.errorCnt++
+	if p.ErrorLimit > 0 && p.errorCnt >= p.ErrorLimit {
+		panic(errorLimitReached{})
+	}
+}
+
+// warn records d as a warning. Warnings do not fail the parse.
+func (p *Parser) warn(d *diag.Diagnostic) {
+	d.Severity = diag.Warning
+	p.errors = append(p.errors, d)
+}
+
+// Warnings returns the warnings of the parse. If it fails, they are also
+// in the returned error among the errors.
+func (p *Parser) Warnings() diag.List {
+	ws := diag.List{}
+	for _, d := range p.errors {
+		if d.Severity == diag.Warning {
+			ws = append(ws, d)
+		}
+	}
+	return ws
+}
+
+// recoverStmt parses a statement. If it is broken, the error is reported,
+// the rest of the statement is skipped and ok is false.
+func (p *Parser) recoverStmt() (stmt ast.Stmt, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			p.report(p.catch(r))
+			p.syncStmt()
+			stmt, ok = nil, false
+		}
+	}()
+
+	return p.stmt(), true
 }
 
-func (p *Parser) Error(token *token.Token, msg string, args ...interface{}) {
-	p.tzer.Error(token, msg, args...)
+// syncStmt skips tokens past the next ";" or past the "}" closing a block
+// opened on the way. It stops before the "}" closing the enclosing block.
+func (p *Parser) syncStmt() {
+	depth := 0
+	for p.cur.Kind != token.EOF {
+		switch p.cur.Kind {
+		case token.SEMICOLLON:
+			if depth == 0 {
+				p.nextTkn()
+				return
+			}
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			if depth == 0 {
+				return
+			}
+
+			depth--
+			if depth == 0 {
+				p.nextTkn()
+				return
+			}
+		}
+		p.nextTkn()
+	}
+}
+
+// recoverGlobal parses a top level declaration. If it is broken, the error
+// is reported, tokens are skipped up to the next declaration and ok is false.
+func (p *Parser) recoverGlobal() (node ast.Node, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			p.report(p.catch(r))
+			p.syncGlobal()
+			node, ok = nil, false
+		}
+	}()
+
+	return p.global(), true
+}
+
+// syncGlobal skips tokens past the next top level ";" or function body, or
+// up to a type name starting the next top level declaration.
+func (p *Parser) syncGlobal() {
+	start := p.cur
+	depth, parens := 0, 0
+	for p.cur.Kind != token.EOF {
+		switch p.cur.Kind {
+		case token.SEMICOLLON:
+			if depth == 0 {
+				p.nextTkn()
+				return
+			}
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			if depth > 0 {
+				depth--
+				if depth == 0 {
+					p.nextTkn()
+					return
+				}
+			}
+		case token.LPAREN:
+			parens++
+		case token.RPAREN:
+			if parens > 0 {
+				parens--
+			}
+		case token.TYPE, token.STRUCT, token.UNION, token.ENUM, token.TYPEDEF:
+			if depth == 0 && parens == 0 && p.cur != start {
+				return
+			}
+		}
+		p.nextTkn()
+	}
+}
+
+// Symbol is a global variable or a function defined in the translation unit.
+type Symbol struct {
+	Name     string
+	Token    *token.Token
+	IsStatic bool
+}
+
+// Symbols returns the symbols defined by the parsed unit in source order.
+// Static ones are local to the unit; the others are seen by the linker.
+func (p *Parser) Symbols() []*Symbol {
+	syms := []*Symbol{}
+	for _, global := range p.Globals {
+		if global.IsEnum || global.IsTypedef {
+			continue
+		}
+		syms = append(syms, &Symbol{Name: global.Name, Token: global.Token(), IsStatic: global.IsStatic})
+	}
+	for _, fn := range p.funcdefs {
+		if fn.Body == nil {
+			continue // only declared
+		}
+		syms = append(syms, &Symbol{Name: fn.Name, Token: fn.Token(), IsStatic: fn.IsStatic})
+	}
+
+	sort.Slice(syms, func(i, j int) bool {
+		return syms[i].Token.Col < syms[j].Token.Col
+	})
+	return syms
 }
 
 func (p *Parser) nextTkn() {
@@ -92,44 +344,140 @@ func (p *Parser) backTo(to *token.Token) {
 	}
 }
 
-func (p *Parser) getDef(name string) *ast.LocalVariable {
+/* Scope */
+
+// scope holds the locals declared in a block. The outermost scope of a
+// function also holds its parameters.
+type scope struct {
+	vars   map[string]*ast.LocalVariable
+	tags   map[string]*tag
+	parent *scope
+	offset int // OffsetCnt of the function on entry
+}
+
+// tag is a struct, union or enum tag. Tags have their own name space:
+// struct x and a variable x do not conflict, but struct x and union x do.
+type tag struct {
+	kind  string        // "struct", "union" or "enum"
+	ty    *types.Struct // nil for an enum
+	token *token.Token  // the tag in the definition, or in the first mention while incomplete
+}
+
+func (p *Parser) enterScope() {
+	p.scope = &scope{
+		vars:   map[string]*ast.LocalVariable{},
+		tags:   map[string]*tag{},
+		parent: p.scope,
+		offset: p.curFn.OffsetCnt,
+	}
+}
+
+// leaveScope frees the stack slots of the scope for the following ones.
+func (p *Parser) leaveScope() {
+	p.curFn.OffsetCnt = p.scope.offset
+	p.scope = p.scope.parent
+}
+
+// getDef looks name up from the innermost block scope to the file scope.
+func (p *Parser) getDef(tkn *token.Token) *ast.LocalVariable {
 	debug("getDef")
-	if p.curFn != nil {
-		debug("getDef p.Locals: %s", p.curFn.Locals)
-		if v, ok := p.curFn.Locals[name]; ok {
+	if v := p.lookup(tkn.Str); v != nil {
+		return v
+	}
+
+	d := p.Error(tkn, "Ident %s not defined.", tkn.Str)
+	d.Code = diag.Undefined
+	panic(d)
+}
+
+// lookup returns the innermost declaration of name, or nil.
+func (p *Parser) lookup(name string) *ast.LocalVariable {
+	for s := p.scope; s != nil; s = s.parent {
+		if v, ok := s.vars[name]; ok {
 			return v
 		}
 	}
+	return p.Globals[name]
+}
 
-	if v, ok := p.Globals[name]; ok {
+// getTypedef returns the typedef name name, or nil if name is not
+// declared or the innermost declaration is not a typedef: int T hides
+// typedef int T of an outer scope.
+func (p *Parser) getTypedef(name string) *ast.LocalVariable {
+	if v := p.lookup(name); v != nil && v.IsTypedef {
 		return v
 	}
-
-	err("Ident %s not defined.\n", name)
-	os.Exit(1)
 	return nil
 }
 
+// scopeVars returns the names declared in the current scope.
+func (p *Parser) scopeVars() map[string]*ast.LocalVariable {
+	if p.scope == nil {
+		return p.Globals
+	}
+	return p.scope.vars
+}
+
+// getTag looks name up like getDef, but in the name space of tags. It
+// returns nil if the tag is not declared.
+func (p *Parser) getTag(name string) *tag {
+	for s := p.scope; s != nil; s = s.parent {
+		if t, ok := s.tags[name]; ok {
+			return t
+		}
+	}
+	return p.tags[name]
+}
+
+// scopeTags returns the tags declared in the current scope.
+func (p *Parser) scopeTags() map[string]*tag {
+	if p.scope == nil {
+		return p.tags
+	}
+	return p.scope.tags
+}
+
+// isTypename reports whether the current token starts a declaration. An
+// identifier does only if it is a typedef name in scope, so that T * x
+// declares x after typedef int T, and multiplies otherwise.
+func (p *Parser) isTypename() bool {
+	switch p.cur.Kind {
+	case token.TYPE, token.STRUCT, token.UNION, token.ENUM, token.TYPEDEF:
+		return true
+	case token.IDENT:
+		return p.getTypedef(p.cur.Str) != nil
+	}
+	return false
+}
+
 func (p *Parser) program() *ast.ProgramNode {
 	node := &ast.ProgramNode{}
 	node.FuncDefs = []*ast.FuncDefNode{}
 	node.GlobalStmts = []*ast.DeclarationStmt{}
 	for p.cur.Kind != token.EOF {
-		n := p.global()
+		n, ok := p.recoverGlobal()
+		if !ok {
+			continue
+		}
+
 		switch n := n.(type) {
 		case *ast.StmtListNode:
 			for _, stmt := range n.Stmts {
 				global, ok := stmt.(*ast.DeclarationStmt)
 				if !ok {
-					p.Error(n.Token(), "Invalid global variable: %s", n)
+					p.report(p.Error(n.Token(), "Invalid global variable: %s", n))
+					continue
 				}
 
 				node.GlobalStmts = append(node.GlobalStmts, global)
 			}
 		case *ast.FuncDefNode:
-			node.FuncDefs = append(node.FuncDefs, n)
+			// A prototype has no code.
+			if n.Body != nil {
+				node.FuncDefs = append(node.FuncDefs, n)
+			}
 		default:
-			p.Error(n.Token(), "Unexpected top level token: '%s' of type '%s'", n)
+			p.report(p.Error(n.Token(), "Unexpected top level token: '%s' of type '%T'", n, n))
 		}
 
 	}
@@ -137,62 +485,235 @@ func (p *Parser) program() *ast.ProgramNode {
 }
 
 func (p *Parser) global() ast.Node {
+	if p.cur.Kind == token.TYPEDEF {
+		return p.typedef()
+	}
+
+	isStatic := false
+	if p.cur.Kind == token.STATIC {
+		isStatic = true
+		p.nextTkn()
+	}
+
+	// The specifiers are parsed only once: a struct must not be defined
+	// twice.
 	start := p.cur
 	baseTy := p.declspec()
+	afterSpec := p.cur
 	ty, identTkn := p.declarator(baseTy)
 	if p.cur.Kind == token.LPAREN {
-		return p.funcdef(ty, identTkn)
+		fn := p.funcdef(ty, identTkn, isStatic)
+		return fn
 	}
 
-	p.backTo(start)
-	return p.declarationStmt(false)
+	p.backTo(afterSpec)
+	stmts := p.declaration(false, start, baseTy)
+	for _, stmt := range stmts.Stmts {
+		for _, local := range stmt.(*ast.DeclarationStmt).LV.Locals {
+			local.IsStatic = isStatic
+		}
+	}
+	return stmts
 }
 
-func (p *Parser) funcdef(ty types.Type, identTkn *token.Token) *ast.FuncDefNode {
-	p.curFn = ast.NewFuncDefNode(p.cur)
+func (p *Parser) funcdef(ty types.Type, identTkn *token.Token, isStatic bool) *ast.FuncDefNode {
+	if identTkn == nil {
+		p.fail(p.cur, "Function name expected.")
+	}
+
+	if _, ok := ty.(*types.Struct); ok {
+		p.fail(identTkn, "Returning %s by value is not supported: %s", ty, identTkn.Str)
+	}
+
+	p.curFn = ast.NewFuncDefNode(identTkn)
 	p.curFn.Type = ty
 	p.curFn.Name = identTkn.Str
-	p.curFn.Args = p.funcdefargs()
+	p.curFn.IsStatic = isStatic
+	p.labels = map[string]*token.Token{}
+	p.gotos = []*ast.GotoStmt{}
+
+	// The parameters and the body share the outermost scope.
+	p.scope = nil
+	p.enterScope()
+	defer p.leaveScope()
+	empty := p.cur.Kind == token.LPAREN && p.cur.Next.Kind == token.RPAREN
+	p.curFn.Args, p.curFn.IsVariadic = p.funcdefargs()
+
+	if p.cur.Kind == token.SEMICOLLON {
+		// int f(); declares f without a prototype like C17. A definition
+		// int f() { ... } takes no arguments like f(void).
+		p.curFn.NoProto = empty
+		p.nextTkn()
+		p.declareFunc(p.curFn, false)
+		return p.curFn
+	}
+
+	for _, arg := range p.curFn.Args.LV.Locals {
+		if arg.Name == "" {
+			p.fail(arg.Token(), "Parameter name omitted")
+		}
+	}
+	p.prepareLocals(p.curFn.Args.LV.Locals)
 
 	// Defined prior to parsing body in order to be called recursively.
-	p.funcdefs[p.curFn.Name] = p.curFn
-	p.curFn.Body = p.blockStmt()
+	p.declareFunc(p.curFn, true)
+	p.curFn.Body = p.block()
+
+	// A label may be defined after the goto to it.
+	for _, stmt := range p.gotos {
+		if _, ok := p.labels[stmt.Label]; !ok {
+			d := p.Error(stmt.Token(), "Label %s not defined.", stmt.Label)
+			d.Code = diag.Undefined
+			p.report(d)
+		}
+	}
 	return p.curFn
 }
 
-func (p *Parser) funcdefargs() *ast.FuncDefArgs {
+// declareFunc declares fn by a prototype, or by a definition if isDef.
+// All declarations of a function must agree on its type. The definition
+// replaces the prototypes, so that it is the one returned by Symbols,
+// and a prototype replaces a declaration without one.
+func (p *Parser) declareFunc(fn *ast.FuncDefNode, isDef bool) {
+	if tkn, ok := p.implicit[fn.Name]; ok && !types.Same(fn.Type, types.GetInt()) {
+		d := p.Error(fn.Token(), "Conflicting types for %s", fn.Name)
+		d.Code = diag.Redefined
+		d.Notef(tkn.Pos(), "previous implicit declaration is here")
+		panic(d)
+	}
+
+	prev, exists := p.funcdefs[fn.Name]
+	if !exists {
+		p.funcdefs[fn.Name] = fn
+		return
+	}
+
+	if isDef && prev.Body != nil {
+		d := p.Error(fn.Token(), "Function already defined: %s", fn.Name)
+		d.Code = diag.Redefined
+		d.Notef(prev.Token().Pos(), "previous definition is here")
+		panic(d)
+	}
+
+	if !sameSignature(prev, fn) {
+		d := p.Error(fn.Token(), "Conflicting types for %s", fn.Name)
+		d.Code = diag.Redefined
+		d.Notef(prev.Token().Pos(), "previous declaration is here")
+		panic(d)
+	}
+
+	// static int f(); int f() { ... } defines a static f.
+	fn.IsStatic = fn.IsStatic || prev.IsStatic
+	if isDef || prev.Body == nil && prev.NoProto && !fn.NoProto {
+		p.funcdefs[fn.Name] = fn
+	}
+}
+
+// sameSignature reports whether a and b have the same return type and
+// parameter types. Only the return types are compared if either has no
+// prototype.
+func sameSignature(a, b *ast.FuncDefNode) bool {
+	if !types.Same(a.Type, b.Type) {
+		return false
+	}
+
+	if a.NoProto || b.NoProto {
+		return true
+	}
+
+	if a.IsVariadic != b.IsVariadic || len(a.Args.LV.Locals) != len(b.Args.LV.Locals) {
+		return false
+	}
+
+	for i, arg := range a.Args.LV.Locals {
+		if !types.Same(arg.Type, b.Args.LV.Locals[i].Type) {
+			return false
+		}
+	}
+	return true
+}
+
+// funcdefargs parses the parameters. variadic is true if they end with
+// "...".
+func (p *Parser) funcdefargs() (args *ast.FuncDefArgs, variadic bool) {
 	p.expect(p.cur, token.LPAREN)
 	lv := ast.NewLocalVariableNode(p.cur)
-	args := &ast.FuncDefArgs{LV: lv}
+	args = &ast.FuncDefArgs{LV: lv}
 	p.nextTkn()
 
+	// f(void) takes no arguments like f().
+	if p.cur.Kind == token.TYPE && p.cur.Str == "void" && p.cur.Next.Kind == token.RPAREN {
+		p.nextTkn()
+	}
+
 	if p.cur.Kind == token.RPAREN {
 		p.nextTkn()
-		return args
+		return args, false
 	}
 
-	basety1 := p.declspec()
-	ty1, identTok := p.declarator(basety1)
-	arg1 := &ast.LocalVariable{Name: identTok.Str, Type: ty1, IsLocal: true}
-	args.LV.Locals = append(args.LV.Locals, arg1)
+	if p.cur.Kind == token.ELLIPSIS {
+		p.fail(p.cur, "A named parameter is required before ...")
+	}
 
+	args.LV.Locals = append(args.LV.Locals, p.param())
 	for p.cur.Kind == token.COMMA {
 		p.nextTkn()
-		basety := p.declspec()
-		ty, identTok := p.declarator(basety)
-		arg := &ast.LocalVariable{Name: identTok.Str, Type: ty, IsLocal: true}
-		args.LV.Locals = append(args.LV.Locals, arg)
+		if p.cur.Kind == token.ELLIPSIS {
+			p.nextTkn()
+			variadic = true
+			break
+		}
+		args.LV.Locals = append(args.LV.Locals, p.param())
+	}
+
+	// An array parameter is a pointer to the first element of the
+	// argument: int a[2] and int a[] are int *a.
+	for _, arg := range args.LV.Locals {
+		if arr, ok := arg.Type.(*types.Array); ok {
+			arg.Type = types.PointerTo(arr.Base)
+		}
 	}
 
 	p.expect(p.cur, token.RPAREN)
 	p.nextTkn()
+	return args, variadic
+}
+
+// param parses a parameter. The name may be omitted in a prototype like
+// int f(int, char *); then the name is empty and the token is the type.
+func (p *Parser) param() *ast.LocalVariable {
+	tkn := p.cur
+	basety := p.declspec()
+	ty, identTok := p.declarator(basety)
+	if identTok == nil {
+		if types.IsVoid(ty) {
+			p.fail(tkn, "void must be the only parameter")
+		}
+
+		if _, ok := ty.(*types.Struct); ok {
+			p.fail(tkn, "Passing %s by value is not supported", ty)
+		}
+		return ast.NewLocalVariable("", ty, true, tkn)
+	}
+
+	if types.IsVoid(ty) {
+		p.fail(identTok, "Parameter %s declared void", identTok.Str)
+	}
 
-	p.prepareLocals(args.LV.Locals)
-	return args
+	if _, ok := ty.(*types.Struct); ok {
+		p.fail(identTok, "Passing %s by value is not supported: %s", ty, identTok.Str)
+	}
+	return ast.NewLocalVariable(identTok.Str, ty, true, identTok)
 }
 
 func (p *Parser) stmt() ast.Stmt {
-	if p.cur.Kind == token.TYPE {
+	// Labels have their own name space: T: is a label even if T is a
+	// typedef name.
+	if p.cur.Kind == token.IDENT && p.cur.Next.Kind == token.COLON {
+		return p.labeledStmt()
+	}
+
+	if p.isTypename() {
 		return p.declarationStmt(true)
 	}
 
@@ -208,6 +729,18 @@ func (p *Parser) stmt() ast.Stmt {
 		return p.whileStmt()
 	}
 
+	if p.cur.Kind == token.DO {
+		return p.doWhileStmt()
+	}
+
+	if p.cur.Kind == token.SWITCH {
+		return p.switchStmt()
+	}
+
+	if p.cur.Kind == token.CASE || p.cur.Kind == token.DEFAULT {
+		return p.caseStmt()
+	}
+
 	if p.cur.Kind == token.IF {
 		return p.ifStmt()
 	}
@@ -216,6 +749,10 @@ func (p *Parser) stmt() ast.Stmt {
 		return p.returnStmt()
 	}
 
+	if p.cur.Kind == token.BREAK || p.cur.Kind == token.CONTINUE || p.cur.Kind == token.GOTO {
+		return p.jumpStmt()
+	}
+
 	exp := p.expr()
 	node := &ast.ExpStmt{Exp: exp}
 	p.expect(p.cur, token.SEMICOLLON)
@@ -225,23 +762,328 @@ func (p *Parser) stmt() ast.Stmt {
 
 func (p *Parser) declspec() types.Type {
 	debug("declspec")
+	if p.cur.Kind == token.STRUCT || p.cur.Kind == token.UNION {
+		return p.structDecl()
+	}
+	if p.cur.Kind == token.ENUM {
+		return p.enumDecl()
+	}
+	if p.cur.Kind == token.IDENT {
+		def := p.getTypedef(p.cur.Str)
+		if def == nil {
+			p.fail(p.cur, "Unknown type name %s", p.cur.Str)
+		}
+		p.nextTkn()
+		return def.Type
+	}
 	p.expect(p.cur, token.TYPE)
 
+	// 指定子は順不同なので数だけ数える。long unsigned intも正しい
 	tkn := p.cur
+	counts := map[string]int{}
+	names := []string{}
+	for p.cur.Kind == token.TYPE {
+		counts[p.cur.Str]++
+		names = append(names, p.cur.Str)
+		p.nextTkn()
+	}
+
+	ty := specType(counts, len(names))
+	if ty == nil {
+		p.fail(tkn, "Invalid type %s", strings.Join(names, " "))
+	}
+	return ty
+}
+
+// specType returns the type of the type specifiers counted by name, or
+// nil if they do not make a type. int may be omitted after short, long
+// and a sign: "unsigned" is unsigned int.
+func specType(counts map[string]int, n int) types.Type {
+	sign := counts["signed"] + counts["unsigned"]
+	if sign > 1 || counts["int"] > 1 {
+		return nil
+	}
+
+	unsigned := counts["unsigned"] == 1
+	rest := n - sign - counts["int"]
+	switch {
+	case counts["void"] == 1 && n == 1:
+		return types.GetVoid()
+	case counts["char"] == 1 && n == sign+1:
+		if unsigned {
+			return types.GetUChar()
+		}
+		return types.GetChar()
+	case counts["short"] == 1 && rest == 1:
+		if unsigned {
+			return types.GetUShort()
+		}
+		return types.GetShort()
+	case counts["long"] >= 1 && counts["long"] <= 2 && rest == counts["long"]:
+		// long longもlongと同じ64bit
+		if unsigned {
+			return types.GetULong()
+		}
+		return types.GetLong()
+	case rest == 0:
+		if unsigned {
+			return types.GetUInt()
+		}
+		return types.GetInt()
+	}
+	return nil
+}
+
+// structDecl parses a struct or union specifier. A tag without members
+// refers to the struct of the tag in scope, or declares an incomplete
+// one, so that a struct can point to itself:
+// struct node { struct node *next; }.
+func (p *Parser) structDecl() types.Type {
+	debug("structDecl")
+	p.expect(p.cur, token.STRUCT, token.UNION)
+	isUnion := p.cur.Kind == token.UNION
+	kind := p.cur.Str
 	p.nextTkn()
 
-	switch tkn.Str {
-	case "int":
+	var tagTkn *token.Token
+	if p.cur.Kind == token.IDENT {
+		tagTkn = p.cur
+		p.nextTkn()
+	}
+
+	if tagTkn == nil {
+		p.expect(p.cur, token.LBRACE)
+		ty := &types.Struct{IsUnion: isUnion}
+		ty.SetMembers(p.members())
+		return ty
+	}
+
+	tags := p.scopeTags()
+	if p.cur.Kind != token.LBRACE {
+		if t := p.getTag(tagTkn.Str); t != nil {
+			p.checkTagKind(t, tagTkn, kind)
+			return t.ty
+		}
+
+		ty := &types.Struct{Tag: tagTkn.Str, IsUnion: isUnion}
+		tags[tagTkn.Str] = &tag{kind: kind, ty: ty, token: tagTkn}
+		return ty
+	}
+
+	// A struct declared in the same scope is completed by the definition.
+	t, ok := tags[tagTkn.Str]
+	if ok {
+		p.checkTagKind(t, tagTkn, kind)
+	}
+
+	if ok && t.ty.IsComplete() {
+		// The members are still parsed to go on after the error.
+		d := p.Error(tagTkn, "Redefinition of %s", t.ty)
+		d.Code = diag.Redefined
+		d.Notef(t.token.Pos(), "previous definition is here")
+		p.report(d)
+		ty := &types.Struct{Tag: tagTkn.Str, IsUnion: isUnion}
+		ty.SetMembers(p.members())
+		return ty
+	}
+
+	if !ok {
+		t = &tag{kind: kind, ty: &types.Struct{Tag: tagTkn.Str, IsUnion: isUnion}}
+		tags[tagTkn.Str] = t
+	}
+	t.token = tagTkn
+	t.ty.SetMembers(p.members())
+	return t.ty
+}
+
+// checkTagKind fails if the tag t is used with another keyword than
+// kind, e.g. union x for struct x.
+func (p *Parser) checkTagKind(t *tag, tagTkn *token.Token, kind string) {
+	if t.kind != kind {
+		d := p.Error(tagTkn, "%s defined as wrong kind of tag", tagTkn.Str)
+		d.Notef(t.token.Pos(), "previous declaration is here")
+		panic(d)
+	}
+}
+
+// enumDecl parses an enum specifier. The type of an enum is int, and the
+// enumerators are int constants in the current scope. Unlike a struct,
+// an enum must be defined before its tag is used alone.
+func (p *Parser) enumDecl() types.Type {
+	debug("enumDecl")
+	p.expect(p.cur, token.ENUM)
+	p.nextTkn()
+
+	var tagTkn *token.Token
+	if p.cur.Kind == token.IDENT {
+		tagTkn = p.cur
+		p.nextTkn()
+	}
+
+	if tagTkn == nil {
+		p.expect(p.cur, token.LBRACE)
+	}
+
+	if p.cur.Kind != token.LBRACE {
+		t := p.getTag(tagTkn.Str)
+		if t == nil {
+			d := p.Error(tagTkn, "Use of undefined enum %s", tagTkn.Str)
+			d.Code = diag.Undefined
+			panic(d)
+		}
+		p.checkTagKind(t, tagTkn, "enum")
 		return types.GetInt()
-	case "char":
-		return types.GetChar()
 	}
 
-	p.Error(tkn, "Invalid type %s", tkn.Str)
+	if tagTkn != nil {
+		tags := p.scopeTags()
+		if t, ok := tags[tagTkn.Str]; ok {
+			p.checkTagKind(t, tagTkn, "enum")
+
+			// The enumerators are still declared to go on after the error.
+			d := p.Error(tagTkn, "Redefinition of enum %s", tagTkn.Str)
+			d.Code = diag.Redefined
+			d.Notef(t.token.Pos(), "previous definition is here")
+			p.report(d)
+		} else {
+			tags[tagTkn.Str] = &tag{kind: "enum", token: tagTkn}
+		}
+	}
+
+	p.enumerators()
+	return types.GetInt()
+}
+
+// enumerators parses the enumerators in {} of an enum. An enumerator
+// without a value is the previous one plus 1, and the first one is 0.
+func (p *Parser) enumerators() {
+	p.expect(p.cur, token.LBRACE)
+	p.nextTkn()
+
+	val := 0
+	for {
+		p.expect(p.cur, token.IDENT)
+		identTok := p.cur
+		p.nextTkn()
+
+		// An implicit value is reported at the enumerator itself.
+		valTok := identTok
+		if p.cur.Kind == token.ASSIGN {
+			p.nextTkn()
+			valTok = p.cur
+			num, ok := p.constExp()
+			if !ok {
+				p.fail(valTok, "Enumerator value for %s is not an integer constant", identTok.Str)
+			}
+			val = num.Val
+		}
+
+		if val < math.MinInt32 || val > math.MaxInt32 {
+			p.fail(valTok, "Enumerator value for %s is out of range of int", identTok.Str)
+		}
+
+		p.declare(ast.NewEnumConstant(identTok.Str, val, identTok))
+		val++
+
+		if p.cur.Kind != token.COMMA {
+			break
+		}
+		p.nextTkn()
+
+		// enum { A, B, } is allowed.
+		if p.cur.Kind == token.RBRACE {
+			break
+		}
+	}
+
+	p.expect(p.cur, token.RBRACE)
+	p.nextTkn()
+}
+
+// declare declares the enumeration constant or the typedef name local in
+// the current scope. They share the name space of variables.
+func (p *Parser) declare(local *ast.LocalVariable) {
+	vars := p.scopeVars()
+	if prev, exists := vars[local.Name]; exists {
+		d := p.Error(local.Token(), "Redeclaration of %s", local.Name)
+		d.Code = diag.Redefined
+		d.Notef(prev.Token().Pos(), "previous declaration is here")
+		panic(d)
+	}
+	vars[local.Name] = local
+}
+
+// constExp parses a conditional expression and reduces it to a constant.
+// ok is false if it is not an integer constant.
+func (p *Parser) constExp() (num *ast.NumExp, ok bool) {
+	exp := p.conditional()
+	if !types.IsInteger(exp.Type()) {
+		return nil, false
+	}
+
+	num, err := ast.Eval(exp)
+	return num, err == nil
+}
+
+// members parses the members in {} of a struct or a union.
+func (p *Parser) members() []*types.Member {
+	p.expect(p.cur, token.LBRACE)
+	p.nextTkn()
+
+	members := []*types.Member{}
+	names := map[string]*token.Token{}
+	for p.cur.Kind != token.RBRACE {
+		baseTy := p.declspec()
+		for {
+			ty, identTok := p.declarator(baseTy)
+			if identTok == nil {
+				p.fail(p.cur, "Identifier expected. Got %s.", p.cur.Kind)
+			}
+
+			// Errors in a member do not stop parsing the struct.
+			if d := p.checkMember(ty, identTok, names); d != nil {
+				p.report(d)
+			} else {
+				names[identTok.Str] = identTok
+				members = append(members, &types.Member{Name: identTok.Str, Type: ty})
+			}
+
+			if p.cur.Kind != token.COMMA {
+				break
+			}
+			p.nextTkn()
+		}
+
+		p.expect(p.cur, token.SEMICOLLON)
+		p.nextTkn()
+	}
+
+	p.nextTkn() // }
+	return members
+}
+
+// checkMember returns the error in the member declaration of ty, or nil.
+func (p *Parser) checkMember(ty types.Type, identTok *token.Token, names map[string]*token.Token) *diag.Diagnostic {
+	if types.IsVoid(ty) {
+		return p.Error(identTok, "Field %s declared void", identTok.Str)
+	}
+
+	if arr, ok := ty.(*types.Array); !types.IsComplete(ty) || ok && arr.Length == 0 {
+		return p.Error(identTok, "Field %s has incomplete type", identTok.Str)
+	}
+
+	if prev, exists := names[identTok.Str]; exists {
+		d := p.Error(identTok, "Duplicate member %s", identTok.Str)
+		d.Code = diag.Redefined
+		d.Notef(prev.Pos(), "previous declaration is here")
+		return d
+	}
 	return nil
 }
 
-// declarator = "*"* ident ("[" num "]")?
+// declarator = "*"* ident ("[" conditional? "]")?
+//
+// The length of "[]" is 0 until an initializer completes the array.
 func (p *Parser) declarator(ty types.Type) (types.Type, *token.Token) {
 	debug("declarator")
 	for p.cur.Kind == token.ASTERISK {
@@ -257,27 +1099,111 @@ func (p *Parser) declarator(ty types.Type) (types.Type, *token.Token) {
 	p.nextTkn()
 
 	if p.cur.Kind == token.LBRACKET {
-		p.nextTkn() // [
-		p.expect(p.cur, token.NUM)
-		length, err := strconv.Atoi(p.cur.Str)
-		if err != nil || length <= 0 {
-			p.tzer.Error(p.cur, "a positive number is expected. got %s.", p.cur.Str)
-			os.Exit(1)
-		}
+		ty = p.arrayOf(ty, identTok, identTok.Str)
+	}
+	return ty, identTok
+}
+
+// arrayOf parses "[" conditional? "]" after the declarator of name at
+// tkn, and returns the array of ty.
+func (p *Parser) arrayOf(ty types.Type, tkn *token.Token, name string) types.Type {
+	if types.IsVoid(ty) {
+		p.fail(tkn, "Declaration of %s as array of voids", name)
+	}
+
+	p.expect(p.cur, token.LBRACKET)
+	p.nextTkn()
+	if p.cur.Kind == token.RBRACKET {
 		p.nextTkn()
-		ty = types.ArrayOf(ty, length)
-		p.expect(p.cur, token.RBRACKET)
+		return types.ArrayOf(ty, 0)
+	}
+
+	start := p.cur
+	num, ok := p.constExp()
+	if !ok {
+		p.fail(start, "Array size of %s is not an integer constant", name)
+	}
+
+	if num.Val <= 0 {
+		p.fail(start, "a positive number is expected. got %s.", num)
+	}
+	p.expect(p.cur, token.RBRACKET)
+	p.nextTkn()
+	return types.ArrayOf(ty, num.Val)
+}
+
+// typename = declspec "*"* ("[" conditional? "]")?
+//
+// typename is a type without a name, as in sizeof(int *).
+func (p *Parser) typename() types.Type {
+	debug("typename")
+	ty := p.declspec()
+	for p.cur.Kind == token.ASTERISK {
+		ty = types.PointerTo(ty)
 		p.nextTkn()
 	}
 
-	return ty, identTok
+	if p.cur.Kind == token.LBRACKET {
+		ty = p.arrayOf(ty, p.cur, "type name")
+	}
+	return ty
 }
 
 func (p *Parser) declarationStmt(isLocal bool) *ast.StmtListNode {
 	debug("declarationStmt")
+	if p.cur.Kind == token.TYPEDEF {
+		return p.typedef()
+	}
+
 	initTok := p.cur
 	baseTy := p.declspec() // "int"
+	return p.declaration(isLocal, initTok, baseTy)
+}
+
+// typedef parses a typedef declaration. Each declarator declares a name
+// for its type instead of a variable, so there are no statements.
+func (p *Parser) typedef() *ast.StmtListNode {
+	debug("typedef")
+	p.expect(p.cur, token.TYPEDEF)
+	p.nextTkn()
+	baseTy := p.declspec()
+
+	for p.cur.Kind != token.SEMICOLLON {
+		ty, identTok := p.declarator(baseTy)
+		if identTok == nil {
+			p.fail(p.cur, "Identifier expected. Got %s.", p.cur.Kind)
+		}
+		p.declareTypedef(ast.NewTypedef(identTok.Str, ty, identTok))
 
+		if p.cur.Kind != token.SEMICOLLON {
+			p.expect(p.cur, token.COMMA)
+			p.nextTkn()
+		}
+	}
+
+	p.nextTkn() // ;
+	return &ast.StmtListNode{Stmts: []ast.Stmt{}}
+}
+
+// declareTypedef declares the typedef name local. Like C11, it may be
+// declared again in the same scope for the same type.
+func (p *Parser) declareTypedef(local *ast.LocalVariable) {
+	prev, exists := p.scopeVars()[local.Name]
+	if !exists || !prev.IsTypedef {
+		p.declare(local)
+		return
+	}
+
+	if !types.Same(prev.Type, local.Type) {
+		d := p.Error(local.Token(), "Conflicting types for %s", local.Name)
+		d.Code = diag.Redefined
+		d.Notef(prev.Token().Pos(), "previous declaration is here")
+		panic(d)
+	}
+}
+
+// declaration parses the declarators after the specifiers of baseTy.
+func (p *Parser) declaration(isLocal bool, initTok *token.Token, baseTy types.Type) *ast.StmtListNode {
 	locals := []*ast.LocalVariable{}
 	stmts := []*ast.DeclarationStmt{}
 
@@ -288,16 +1214,43 @@ func (p *Parser) declarationStmt(isLocal bool) *ast.StmtListNode {
 		}
 
 		ty, identTok := p.declarator(baseTy) // "**a"
+		if identTok == nil {
+			p.fail(p.cur, "Identifier expected. Got %s.", p.cur.Kind)
+		}
+
+		if types.IsVoid(ty) {
+			p.fail(identTok, "Variable %s declared void", identTok.Str)
+		}
+
+		if !types.IsComplete(ty) {
+			p.fail(identTok, "Storage size of %s isn't known", identTok.Str)
+		}
+
+		arr, incomplete := ty.(*types.Array)
+		incomplete = incomplete && arr.Length == 0
+		if incomplete {
+			// The initializer completes a copy: the type may be shared
+			// by typedef int A[].
+			arr = &types.Array{Base: arr.Base}
+			ty = arr
+		}
 
-		local := &ast.LocalVariable{Name: identTok.Str, Type: ty, IsLocal: isLocal}
+		local := ast.NewLocalVariable(identTok.Str, ty, isLocal, identTok)
 		locals = append(locals, local)
 
 		if p.cur.Kind != token.ASSIGN {
+			if incomplete {
+				p.fail(identTok, "Array size missing in %s.", identTok.Str)
+			}
 			continue
 		}
 
 		p.nextTkn() // "="
-		p.prepareLocals(locals)
+
+		// The size of int a[] = {...} is known only after the initializer.
+		if !incomplete {
+			p.prepareLocals(locals)
+		}
 
 		left := ast.NewLocalVariableNode(initTok)
 		left.Locals = locals
@@ -305,16 +1258,28 @@ func (p *Parser) declarationStmt(isLocal bool) *ast.StmtListNode {
 		var right ast.Exp
 		if p.cur.Kind == token.LBRACE {
 			ident := ast.NewIdentExp(identTok.Str, identTok, ty)
+			ident.Var = local
 			right = p.arrayliteral(ident)
 		} else {
-			right = p.expr()
+			right = p.assign()
 		}
 
-		declStmt := ast.NewDeclarationStmt(left, right, "=", initTok)
-		err := declStmt.CheckTypeError()
-		if err != nil {
-			p.Error(declStmt.Token(), err.Error())
+		if incomplete {
+			switch right := right.(type) {
+			case *ast.ArrayLiteral:
+				arr.Length = len(right.Exps)
+			case *ast.StringLiteralExp:
+				arr.Length = right.Length()
+			}
+
+			if arr.Length == 0 {
+				p.fail(identTok, "Array size missing in %s.", identTok.Str)
+			}
+			p.prepareLocals(locals)
 		}
+
+		declStmt := ast.NewDeclarationStmt(left, right, "=", initTok)
+		p.check(declStmt.CheckTypeError())
 		stmts = append(stmts, declStmt)
 		locals = []*ast.LocalVariable{}
 	}
@@ -325,10 +1290,7 @@ func (p *Parser) declarationStmt(isLocal bool) *ast.StmtListNode {
 		left := ast.NewLocalVariableNode(initTok)
 		left.Locals = locals
 		declStmt := ast.NewDeclarationStmt(left, nil, "=", initTok)
-		err := declStmt.CheckTypeError()
-		if err != nil {
-			p.Error(declStmt.Token(), err.Error())
-		}
+		p.check(declStmt.CheckTypeError())
 		stmts = append(stmts, declStmt)
 	}
 
@@ -353,12 +1315,12 @@ func (p *Parser) arrayliteral(ident *ast.IdentExp) *ast.ArrayLiteral {
 		return node
 	}
 
-	exp := p.expr()
+	exp := p.assign()
 	node.Exps = append(node.Exps, exp)
 
 	for p.cur.Kind == token.COMMA {
 		p.nextTkn()
-		exp := p.expr()
+		exp := p.assign()
 		node.Exps = append(node.Exps, exp)
 	}
 
@@ -368,15 +1330,25 @@ func (p *Parser) arrayliteral(ident *ast.IdentExp) *ast.ArrayLiteral {
 }
 
 func (p *Parser) blockStmt() *ast.BlockStmt {
+	p.enterScope()
+	defer p.leaveScope()
+	return p.block()
+}
+
+// block parses a block in the current scope.
+func (p *Parser) block() *ast.BlockStmt {
 	p.expect(p.cur, token.LBRACE)
 	tkn := p.cur
 	p.nextTkn() // {
 	node := ast.NewBlockStmt(tkn)
 	stmtList := &ast.StmtListNode{Stmts: []ast.Stmt{}}
-	for p.cur.Kind != token.RBRACE {
-		stmtList.Stmts = append(stmtList.Stmts, p.stmt())
+	for p.cur.Kind != token.RBRACE && p.cur.Kind != token.EOF {
+		if stmt, ok := p.recoverStmt(); ok {
+			stmtList.Stmts = append(stmtList.Stmts, stmt)
+		}
 	}
 	node.Stmts = stmtList
+	p.expect(p.cur, token.RBRACE)
 	p.nextTkn() // }
 	return node
 }
@@ -386,11 +1358,15 @@ func (p *Parser) forStmt() *ast.ForStmt {
 	tkn := p.cur
 	node := ast.NewForStmt(tkn)
 	p.nextTkn()
+
+	// A declaration in the header is visible only in the for statement.
+	p.enterScope()
+	defer p.leaveScope()
 	p.expect(p.cur, token.LPAREN)
 	p.nextTkn()
 
 	if p.cur.Kind != token.SEMICOLLON {
-		if p.cur.Kind == token.TYPE {
+		if p.isTypename() {
 			node.Init = p.declarationStmt(true)
 		} else {
 			node.Init = p.expr()
@@ -404,6 +1380,7 @@ func (p *Parser) forStmt() *ast.ForStmt {
 
 	if p.cur.Kind != token.SEMICOLLON {
 		node.Cond = p.expr()
+		p.check(ast.CheckVoid(node.Cond))
 	}
 	p.expect(p.cur, token.SEMICOLLON)
 	p.nextTkn()
@@ -414,10 +1391,21 @@ func (p *Parser) forStmt() *ast.ForStmt {
 	p.expect(p.cur, token.RPAREN)
 	p.nextTkn()
 
-	node.Body = p.stmt()
+	node.Body = p.loopBody()
 	return node
 }
 
+// loopBody parses the body of a loop, in which break and continue are allowed.
+func (p *Parser) loopBody() ast.Stmt {
+	p.breakDepth++
+	p.continueDepth++
+	defer func() {
+		p.breakDepth--
+		p.continueDepth--
+	}()
+	return p.stmt()
+}
+
 func (p *Parser) whileStmt() *ast.WhileStmt {
 	p.expect(p.cur, token.WHILE)
 	tkn := p.cur
@@ -425,12 +1413,163 @@ func (p *Parser) whileStmt() *ast.WhileStmt {
 	p.expect(p.cur, token.LPAREN)
 	p.nextTkn()
 	exp := p.expr()
+	p.check(ast.CheckVoid(exp))
 	p.expect(p.cur, token.RPAREN)
 	p.nextTkn()
-	body := p.stmt()
+	body := p.loopBody()
 	return ast.NewWhileStmt(exp, body, tkn)
 }
 
+func (p *Parser) doWhileStmt() *ast.DoWhileStmt {
+	p.expect(p.cur, token.DO)
+	tkn := p.cur
+	p.nextTkn()
+	body := p.loopBody()
+	p.expect(p.cur, token.WHILE)
+	p.nextTkn()
+	p.expect(p.cur, token.LPAREN)
+	p.nextTkn()
+	exp := p.expr()
+	p.check(ast.CheckVoid(exp))
+	p.expect(p.cur, token.RPAREN)
+	p.nextTkn()
+	p.expect(p.cur, token.SEMICOLLON)
+	p.nextTkn()
+	return ast.NewDoWhileStmt(body, exp, tkn)
+}
+
+func (p *Parser) switchStmt() *ast.SwitchStmt {
+	p.expect(p.cur, token.SWITCH)
+	tkn := p.cur
+	p.nextTkn()
+	p.expect(p.cur, token.LPAREN)
+	p.nextTkn()
+	exp := p.expr()
+	p.expect(p.cur, token.RPAREN)
+	p.nextTkn()
+
+	node := ast.NewSwitchStmt(exp, tkn)
+	p.check(node.CheckTypeError())
+	node.Body = p.switchBody(node)
+	return node
+}
+
+// switchBody parses the body of node, in which break and the labels of
+// node are allowed.
+func (p *Parser) switchBody(node *ast.SwitchStmt) ast.Stmt {
+	p.breakDepth++
+	p.switches = append(p.switches, node)
+	p.caseVals = append(p.caseVals, map[int]*ast.CaseStmt{})
+	defer func() {
+		p.breakDepth--
+		p.switches = p.switches[:len(p.switches)-1]
+		p.caseVals = p.caseVals[:len(p.caseVals)-1]
+	}()
+	return p.stmt()
+}
+
+func (p *Parser) caseStmt() *ast.CaseStmt {
+	tkn := p.cur
+	if len(p.switches) == 0 {
+		p.fail(tkn, "%s label not within a switch statement", tkn.Str)
+	}
+	p.nextTkn()
+
+	// A value that is not an integer constant is reported by the generator.
+	var exp ast.Exp
+	if tkn.Kind == token.CASE {
+		exp = p.conditional()
+	}
+	p.expect(p.cur, token.COLON)
+	p.nextTkn()
+
+	node := ast.NewCaseStmt(exp, nil, tkn)
+	sw := p.switches[len(p.switches)-1]
+	if exp != nil {
+		sw.Cases = append(sw.Cases, node)
+		p.checkCaseValue(node)
+	} else if sw.Default != nil {
+		d := p.Error(tkn, "Multiple default labels in one switch")
+		d.Code = diag.Redefined
+		d.Notef(sw.Default.Token().Pos(), "previous default is here")
+		panic(d)
+	} else {
+		sw.Default = node
+	}
+
+	node.Stmt = p.stmt()
+	return node
+}
+
+// checkCaseValue reports the value of c if another case of the innermost
+// switch has it. Values are compared after conversion to the type of the
+// condition.
+func (p *Parser) checkCaseValue(c *ast.CaseStmt) {
+	if !types.IsInteger(c.Exp.Type()) {
+		return
+	}
+	num, err := ast.Eval(c.Exp)
+	if err != nil {
+		return
+	}
+
+	sw := p.switches[len(p.switches)-1]
+	seen := p.caseVals[len(p.caseVals)-1]
+	val := types.Convert(num.Val, types.Promote(sw.Cond.Type()))
+	if prev, ok := seen[val]; ok {
+		d := p.Error(c.Token(), "Duplicate case value: %d", val)
+		d.Code = diag.Redefined
+		d.Notef(prev.Token().Pos(), "previously used here")
+		p.report(d)
+		return
+	}
+	seen[val] = c
+}
+
+func (p *Parser) jumpStmt() ast.Stmt {
+	tkn := p.cur
+	p.nextTkn()
+
+	var node ast.Stmt
+	switch tkn.Kind {
+	case token.BREAK:
+		if p.breakDepth == 0 {
+			p.fail(tkn, "break statement not within loop or switch")
+		}
+		node = ast.NewBreakStmt(tkn)
+	case token.CONTINUE:
+		if p.continueDepth == 0 {
+			p.fail(tkn, "continue statement not within a loop")
+		}
+		node = ast.NewContinueStmt(tkn)
+	case token.GOTO:
+		p.expect(p.cur, token.IDENT)
+		stmt := ast.NewGotoStmt(p.cur.Str, p.cur)
+		p.gotos = append(p.gotos, stmt)
+		p.nextTkn()
+		node = stmt
+	}
+
+	p.expect(p.cur, token.SEMICOLLON)
+	p.nextTkn()
+	return node
+}
+
+func (p *Parser) labeledStmt() *ast.LabeledStmt {
+	tkn := p.cur
+	if prev, exists := p.labels[tkn.Str]; exists {
+		d := p.Error(tkn, "Label already defined: %s", tkn.Str)
+		d.Code = diag.Redefined
+		d.Notef(prev.Pos(), "previous definition is here")
+		panic(d)
+	}
+	p.labels[tkn.Str] = tkn
+
+	p.nextTkn() // ident
+	p.nextTkn() // :
+	return ast.NewLabeledStmt(tkn.Str, p.stmt(), tkn)
+}
+
 func (p *Parser) ifStmt() *ast.IfStmt {
 	p.expect(p.cur, token.IF)
 	tkn := p.cur
@@ -438,6 +1577,7 @@ func (p *Parser) ifStmt() *ast.IfStmt {
 	p.expect(p.cur, token.LPAREN)
 	p.nextTkn()
 	exp := p.expr()
+	p.check(ast.CheckVoid(exp))
 	p.expect(p.cur, token.RPAREN)
 	p.nextTkn()
 	ifBody := p.stmt()
@@ -453,7 +1593,21 @@ func (p *Parser) returnStmt() *ast.ReturnStmt {
 	p.expect(p.cur, token.RETURN)
 	tkn := p.cur
 	p.nextTkn()
+	fn := p.curFn
+	if p.cur.Kind == token.SEMICOLLON {
+		if !types.IsVoid(fn.Type) {
+			p.fail(tkn, "Non-void function %s should return a value", fn.Name)
+		}
+		p.nextTkn()
+		return ast.NewReturnStmt(nil, tkn)
+	}
+
 	exp := p.expr()
+	if types.IsVoid(fn.Type) {
+		p.fail(tkn, "Void function %s should not return a value", fn.Name)
+	}
+	p.check(ast.CheckVoid(exp))
+
 	node := ast.NewReturnStmt(exp, tkn)
 	p.expect(p.cur, token.SEMICOLLON)
 	p.nextTkn()
@@ -462,14 +1616,37 @@ func (p *Parser) returnStmt() *ast.ReturnStmt {
 
 func (p *Parser) expr() ast.Exp {
 	debug("expr")
-	return p.assign()
+	node := p.assign()
+
+	for p.cur.Kind == token.COMMA {
+		comma := ast.NewCommaExp(node, nil, p.cur)
+		p.nextTkn()
+		comma.Right = p.assign()
+		node = comma
+	}
+
+	return node
+}
+
+var assignOps = map[token.TokenKind]bool{
+	token.ASSIGN:     true,
+	token.ADD_ASSIGN: true,
+	token.SUB_ASSIGN: true,
+	token.MUL_ASSIGN: true,
+	token.DIV_ASSIGN: true,
+	token.MOD_ASSIGN: true,
+	token.AND_ASSIGN: true,
+	token.OR_ASSIGN:  true,
+	token.XOR_ASSIGN: true,
+	token.SHL_ASSIGN: true,
+	token.SHR_ASSIGN: true,
 }
 
 func (p *Parser) assign() ast.Exp {
 	debug("assign")
-	node := p.eq()
+	node := p.conditional()
 
-	if p.cur.Kind == token.ASSIGN {
+	if assignOps[p.cur.Kind] {
 		infix := ast.NewInfixExp(node, nil, p.cur.Str, p.cur)
 		p.nextTkn() // =
 		infix.Right = p.assign()
@@ -477,13 +1654,103 @@ func (p *Parser) assign() ast.Exp {
 
 		// TODO: duplicate left value check
 		if ident, ok := infix.Left.(*ast.IdentExp); ok {
-			_ = p.getDef(ident.Name)
+			_ = p.getDef(ident.Token())
 		}
 
-		err := infix.CheckTypeError()
-		if err != nil {
-			p.Error(node.Token(), err.Error())
-		}
+		p.check(infix.CheckTypeError())
+	}
+
+	return node
+}
+
+func (p *Parser) conditional() ast.Exp {
+	debug("conditional")
+	node := p.logor()
+
+	if p.cur.Kind == token.QUESTION {
+		cond := ast.NewCondExp(node, nil, nil, p.cur)
+		p.nextTkn() // ?
+		cond.Then = p.expr()
+		p.expect(p.cur, token.COLON)
+		p.nextTkn() // :
+		cond.Else = p.conditional()
+		node = cond
+		p.check(cond.CheckTypeError())
+	}
+
+	return node
+}
+
+func (p *Parser) logor() ast.Exp {
+	debug("logor")
+	node := p.logand()
+
+	for p.cur.Kind == token.LOR {
+		infix := ast.NewInfixExp(node, nil, p.cur.Str, p.cur)
+		p.nextTkn()
+		infix.Right = p.logand()
+		node = infix
+		p.check(infix.CheckTypeError())
+	}
+
+	return node
+}
+
+func (p *Parser) logand() ast.Exp {
+	debug("logand")
+	node := p.bitor()
+
+	for p.cur.Kind == token.LAND {
+		infix := ast.NewInfixExp(node, nil, p.cur.Str, p.cur)
+		p.nextTkn()
+		infix.Right = p.bitor()
+		node = infix
+		p.check(infix.CheckTypeError())
+	}
+
+	return node
+}
+
+func (p *Parser) bitor() ast.Exp {
+	debug("bitor")
+	node := p.bitxor()
+
+	for p.cur.Kind == token.OR {
+		infix := ast.NewInfixExp(node, nil, p.cur.Str, p.cur)
+		p.nextTkn()
+		infix.Right = p.bitxor()
+		node = infix
+		p.check(infix.CheckTypeError())
+	}
+
+	return node
+}
+
+func (p *Parser) bitxor() ast.Exp {
+	debug("bitxor")
+	node := p.bitand()
+
+	for p.cur.Kind == token.XOR {
+		infix := ast.NewInfixExp(node, nil, p.cur.Str, p.cur)
+		p.nextTkn()
+		infix.Right = p.bitand()
+		node = infix
+		p.check(infix.CheckTypeError())
+	}
+
+	return node
+}
+
+func (p *Parser) bitand() ast.Exp {
+	debug("bitand")
+	node := p.eq()
+
+	for p.cur.Kind == token.AND {
+		infix := ast.NewInfixExp(node, nil, p.cur.Str, p.cur)
+		p.nextTkn()
+		infix.Right = p.eq()
+		node = infix
+		p.check(infix.CheckTypeError())
 	}
 
 	return node
@@ -498,10 +1765,7 @@ func (p *Parser) eq() ast.Exp {
 		p.nextTkn()
 		infix.Right = p.lg()
 		node = infix
-		err := infix.CheckTypeError()
-		if err != nil {
-			p.Error(node.Token(), err.Error())
-		}
+		p.check(infix.CheckTypeError())
 	}
 
 	return node
@@ -509,7 +1773,7 @@ func (p *Parser) eq() ast.Exp {
 
 func (p *Parser) lg() ast.Exp {
 	debug("lg")
-	node := p.add()
+	node := p.shift()
 
 	switch p.cur.Kind {
 	case token.LT:
@@ -519,14 +1783,26 @@ func (p *Parser) lg() ast.Exp {
 	case token.LTE:
 		fallthrough
 	case token.GTE:
+		infix := ast.NewInfixExp(node, nil, p.cur.Str, p.cur)
+		p.nextTkn()
+		infix.Right = p.shift()
+		node = infix
+		p.check(infix.CheckTypeError())
+	}
+
+	return node
+}
+
+func (p *Parser) shift() ast.Exp {
+	debug("shift")
+	node := p.add()
+
+	for p.cur.Kind == token.SHL || p.cur.Kind == token.SHR {
 		infix := ast.NewInfixExp(node, nil, p.cur.Str, p.cur)
 		p.nextTkn()
 		infix.Right = p.add()
 		node = infix
-		err := infix.CheckTypeError()
-		if err != nil {
-			p.Error(node.Token(), err.Error())
-		}
+		p.check(infix.CheckTypeError())
 	}
 
 	return node
@@ -545,13 +1821,10 @@ func (p *Parser) add() ast.Exp {
 			p.nextTkn()
 			infix.Right = p.mul()
 			node = infix
-			err := infix.CheckTypeError()
-			if err != nil {
-				p.Error(node.Token(), err.Error())
-			}
+			p.check(infix.CheckTypeError())
 		default:
 			// never go here
-			p.tzer.Error(p.cur, "Invalid token: %s", p.cur.Str)
+			p.fail(p.cur, "Invalid token: %s", p.cur.Str)
 		}
 	}
 
@@ -562,22 +1835,21 @@ func (p *Parser) mul() ast.Exp {
 	debug("mul")
 	node := p.unary()
 
-	for p.cur.Kind == token.ASTERISK || p.cur.Kind == token.SLASH {
+	for p.cur.Kind == token.ASTERISK || p.cur.Kind == token.SLASH || p.cur.Kind == token.PERCENT {
 		switch p.cur.Kind {
 		case token.ASTERISK:
 			fallthrough
 		case token.SLASH:
+			fallthrough
+		case token.PERCENT:
 			infix := ast.NewInfixExp(node, nil, p.cur.Str, p.cur)
 			p.nextTkn()
 			infix.Right = p.unary()
 			node = infix
-			err := infix.CheckTypeError()
-			if err != nil {
-				p.Error(node.Token(), err.Error())
-			}
+			p.check(infix.CheckTypeError())
 		default:
 			// never go here
-			p.tzer.Error(p.cur, "Invalid token: %s", p.cur.Str)
+			p.fail(p.cur, "Invalid token: %s", p.cur.Str)
 		}
 	}
 
@@ -586,6 +1858,10 @@ func (p *Parser) mul() ast.Exp {
 
 func (p *Parser) unary() ast.Exp {
 	debug("unary")
+	if p.cur.Kind == token.SIZEOF && p.cur.Next.Kind == token.LPAREN && p.startsTypename(p.cur.Next.Next) {
+		return p.sizeofType()
+	}
+
 	switch p.cur.Kind {
 	case token.PLUS:
 		fallthrough
@@ -595,17 +1871,136 @@ func (p *Parser) unary() ast.Exp {
 		fallthrough
 	case token.SIZEOF:
 		fallthrough
+	case token.NOT:
+		fallthrough
+	case token.TILDE:
+		fallthrough
+	case token.INC:
+		fallthrough
+	case token.DEC:
+		fallthrough
 	case token.AND:
 		node := ast.NewUnaryExp(nil, p.cur.Str, p.cur)
 		p.nextTkn()
-		node.Right = p.primary()
+		node.Right = p.postfix()
+		p.check(node.CheckTypeError())
 		return node
 	default:
-		n := p.primary()
+		n := p.postfix()
 		return n
 	}
 }
 
+// startsTypename reports whether tkn starts a type name. Unlike
+// isTypename, storage classes like typedef do not. A typedef name does
+// unless a variable hides it: sizeof(T) is then sizeof of the variable.
+func (p *Parser) startsTypename(tkn *token.Token) bool {
+	switch tkn.Kind {
+	case token.TYPE, token.STRUCT, token.UNION, token.ENUM:
+		return true
+	case token.IDENT:
+		return p.getTypedef(tkn.Str) != nil
+	}
+	return false
+}
+
+// sizeofType parses "sizeof" "(" typename ")". It is a constant like
+// sizeof of an expression.
+func (p *Parser) sizeofType() ast.Exp {
+	p.expect(p.cur, token.SIZEOF)
+	tkn := p.cur
+	p.nextTkn()
+	p.expect(p.cur, token.LPAREN)
+	p.nextTkn()
+
+	ty := p.typename()
+	if arr, ok := ty.(*types.Array); !types.IsComplete(ty) || ok && arr.Length == 0 {
+		d := p.Error(tkn, "Invalid application of sizeof to incomplete type %s", ty)
+		d.Code = diag.Type
+		panic(d)
+	}
+
+	p.expect(p.cur, token.RPAREN)
+	p.nextTkn()
+	return ast.NewTypedNumExp(ty.StackSize(), tkn, types.GetInt())
+}
+
+func (p *Parser) postfix() ast.Exp {
+	debug("postfix")
+	node := p.primary()
+
+	for {
+		switch p.cur.Kind {
+		case token.LBRACKET:
+			node = p.index(node)
+		case token.DOT, token.ARROW:
+			node = p.member(node)
+		case token.INC, token.DEC:
+			postfix := ast.NewPostfixExp(node, p.cur.Str, p.cur)
+			p.nextTkn()
+			node = postfix
+			p.check(postfix.CheckTypeError())
+		default:
+			return node
+		}
+	}
+}
+
+// index parses [] after an expression other than an array variable,
+// e.g. p[1] or s.a[1], as *(left + index).
+func (p *Parser) index(left ast.Exp) ast.Exp {
+	tkn := p.cur
+	p.nextTkn() // [
+	index := p.expr()
+	p.expect(p.cur, token.RBRACKET)
+	p.nextTkn() // ]
+
+	add := ast.NewInfixExp(left, index, "+", tkn)
+	p.check(add.CheckTypeError())
+	if _, ok := add.Type().(*types.IntPointer); !ok {
+		if _, ok := add.Type().(*types.Array); !ok {
+			p.fail(tkn, "Subscripted value is neither array nor pointer: %s", left)
+		}
+	}
+
+	deref := ast.NewUnaryExp(add, "*", tkn)
+	p.check(deref.CheckTypeError())
+	return deref
+}
+
+// member parses .name or ->name after left.
+func (p *Parser) member(left ast.Exp) ast.Exp {
+	opTkn := p.cur
+	p.nextTkn()
+	p.expect(p.cur, token.IDENT)
+	nameTkn := p.cur
+	p.nextTkn()
+
+	ty := left.Type()
+	if opTkn.Kind == token.ARROW {
+		ptr, ok := ty.(*types.IntPointer)
+		if !ok {
+			p.fail(opTkn, "Invalid type argument of ->: %s", ty)
+		}
+		ty = ptr.Base
+	}
+
+	st, ok := ty.(*types.Struct)
+	if !ok {
+		p.fail(nameTkn, "Request for member %s in something not a structure or union: %s", nameTkn.Str, left)
+	}
+
+	if !st.IsComplete() {
+		p.fail(nameTkn, "Dereferencing pointer to incomplete type %s", st)
+	}
+
+	m := st.Member(nameTkn.Str)
+	if m == nil {
+		p.fail(nameTkn, "%s has no member named %s", st, nameTkn.Str)
+	}
+	return ast.NewMemberExp(left, opTkn.Str, m, nameTkn)
+}
+
 func (p *Parser) primary() ast.Exp {
 	debug("primary")
 	switch p.cur.Kind {
@@ -621,12 +2016,13 @@ func (p *Parser) primary() ast.Exp {
 			return exp
 		}
 
-		if p.cur.Kind == token.LBRACKET {
+		// [] on others is parsed by postfix as *(p + i).
+		if _, ok := ident.Type().(*types.Array); ok && p.cur.Kind == token.LBRACKET {
 			p.nextTkn() // [
 			index := p.expr()
 			p.expect(p.cur, token.RBRACKET)
 			p.nextTkn() // ]
-			_ = p.getDef(ident.Name)
+			_ = p.getDef(ident.Token())
 			return ast.NewIndexExp(ident, index, ident.Token())
 		}
 
@@ -637,6 +2033,14 @@ func (p *Parser) primary() ast.Exp {
 		p.expect(p.cur, token.RPAREN)
 		p.nextTkn() // )
 		return n
+	case token.NOT:
+		fallthrough
+	case token.TILDE:
+		fallthrough
+	case token.INC:
+		fallthrough
+	case token.DEC:
+		fallthrough
 	case token.AND:
 		fallthrough
 	case token.ASTERISK:
@@ -646,18 +2050,70 @@ func (p *Parser) primary() ast.Exp {
 	case token.MINUS:
 		return p.unary()
 	default:
-		p.tzer.Error(p.cur, "Invalid token as primary: %s", p.cur.Str)
+		p.fail(p.cur, "Invalid token as primary: %s", p.cur.Str)
 		return nil
 	}
 }
 
 func (p *Parser) num() ast.Exp {
 	p.expect(p.cur, token.NUM)
-	node := ast.NewNumExp(p.cur.Val, p.cur)
+	node := ast.NewTypedNumExp(p.cur.Val, p.cur, p.numType(p.cur))
 	p.nextTkn()
 	return node
 }
 
+// numType returns the first type in which the literal fits, from the
+// candidates of its suffix and base (C11 6.4.4.1).
+func (p *Parser) numType(tkn *token.Token) types.Type {
+	if strings.HasPrefix(tkn.Str, "'") {
+		return types.GetInt()
+	}
+
+	num := strings.TrimRight(tkn.Str, "uUlL")
+	suffix := strings.ToLower(tkn.Str[len(num):])
+	decimal := num == "0" || !strings.HasPrefix(num, "0")
+	unsigned := strings.Contains(suffix, "u")
+	long := strings.Contains(suffix, "l")
+
+	var candidates []types.Type
+	switch {
+	case unsigned && long:
+		candidates = []types.Type{types.GetULong()}
+	case unsigned:
+		candidates = []types.Type{types.GetUInt(), types.GetULong()}
+	case long && decimal:
+		candidates = []types.Type{types.GetLong()}
+	case long:
+		candidates = []types.Type{types.GetLong(), types.GetULong()}
+	case decimal:
+		candidates = []types.Type{types.GetInt(), types.GetLong()}
+	default:
+		candidates = []types.Type{types.GetInt(), types.GetUInt(), types.GetLong(), types.GetULong()}
+	}
+
+	val := uint64(tkn.Val)
+	for _, ty := range candidates {
+		if val <= maxValue(ty) {
+			return ty
+		}
+	}
+
+	p.fail(tkn, "Integer literal %s is too large to be represented in any integer type", tkn.Str)
+	return nil
+}
+
+func maxValue(ty types.Type) uint64 {
+	switch ty {
+	case types.GetInt():
+		return math.MaxInt32
+	case types.GetUInt():
+		return math.MaxUint32
+	case types.GetLong():
+		return math.MaxInt64
+	}
+	return math.MaxUint64
+}
+
 func (p *Parser) str() ast.Exp {
 	p.expect(p.cur, token.STRING)
 	lbl := p.getLbl()
@@ -675,8 +2131,18 @@ func (p *Parser) ident() ast.Exp {
 	if p.cur.Kind == token.LPAREN {
 		return p.funccall(tkn)
 	} else {
-		local := p.getDef(tkn.Str)
-		return ast.NewIdentExp(tkn.Str, tkn, local.Type)
+		local := p.getDef(tkn)
+		if local.IsEnum {
+			return ast.NewTypedNumExp(local.Val, tkn, local.Type)
+		}
+
+		if local.IsTypedef {
+			p.fail(tkn, "Unexpected type name %s", tkn.Str)
+		}
+
+		ident := ast.NewIdentExp(tkn.Str, tkn, local.Type)
+		ident.Var = local
+		return ident
 	}
 }
 
@@ -685,50 +2151,109 @@ func (p *Parser) funccall(identTkn *token.Token) *ast.FuncCallExp {
 	p.expect(p.cur, token.LPAREN)
 	p.nextTkn()
 
+	// 宣言の無い関数はintを返すとみなす. 定義は後で、または別の翻訳単位で
+	// リンクされる
 	def, ok := p.funcdefs[identTkn.Str]
-	if !ok {
-		// コンパイル後にリンクされるので問題無し.
+	if _, warned := p.implicit[identTkn.Str]; !ok && !warned {
+		p.implicit[identTkn.Str] = identTkn
+		d := p.Error(identTkn, "Implicit declaration of function %s", identTkn.Str)
+		d.Code = diag.ImplicitDecl
+		p.warn(d)
 	}
 
 	exp := ast.NewFuncCallExp(identTkn.Str, nil, identTkn, def)
 
-	if p.cur.Kind == token.RPAREN {
-		p.nextTkn()
-		return exp
+	starts := []*token.Token{}
+	if p.cur.Kind != token.RPAREN {
+		exp.Params, starts = p.funccallparams()
 	}
 
-	exp.Params = p.funccallparams()
+	p.expect(p.cur, token.RPAREN)
+	rparen := p.cur
+	p.nextTkn()
+
+	if ok {
+		p.checkArgs(exp, starts, rparen)
+	}
 	return exp
 }
 
-func (p *Parser) funccallparams() *ast.FuncCallParams {
+// funccallparams parses the arguments of a call. It also returns the
+// first token of each argument to point at it in errors.
+func (p *Parser) funccallparams() (*ast.FuncCallParams, []*token.Token) {
 	params := &ast.FuncCallParams{Exps: []ast.Exp{}}
-	param1 := p.expr()
-	params.Exps = append(params.Exps, param1)
-	for p.cur.Kind == token.COMMA {
-		p.nextTkn()
-		param := p.expr()
+	starts := []*token.Token{}
+	for {
+		starts = append(starts, p.cur)
+		param := p.assign()
+		p.check(ast.CheckVoid(param))
 		params.Exps = append(params.Exps, param)
+
+		if p.cur.Kind != token.COMMA {
+			break
+		}
+		p.nextTkn()
 	}
+	return params, starts
+}
 
-	p.expect(p.cur, token.RPAREN)
-	p.nextTkn()
-	return params
+// checkArgs checks the number and the types of the arguments of exp
+// against the declaration of the callee. Each argument is converted to
+// the type of its parameter like an assignment. The arguments for "..."
+// and those of a function without a prototype are not checked.
+func (p *Parser) checkArgs(exp *ast.FuncCallExp, starts []*token.Token, rparen *token.Token) {
+	if exp.Def.NoProto {
+		return
+	}
+
+	args := exp.Def.Args.LV.Locals
+	params := exp.Params.Exps
+	if len(params) > len(args) && !exp.Def.IsVariadic {
+		d := p.Error(starts[len(args)], "Too many arguments to function %s", exp.Name)
+		d.Code = diag.Type
+		d.Notef(exp.Def.Token().Pos(), "declared here")
+		p.report(d)
+		return
+	}
+
+	if len(params) < len(args) {
+		d := p.Error(rparen, "Too few arguments to function %s", exp.Name)
+		d.Code = diag.Type
+		d.Notef(exp.Def.Token().Pos(), "declared here")
+		p.report(d)
+		return
+	}
+
+	for i, param := range params[:len(args)] {
+		if !ast.CanAssign(args[i].Type, param) {
+			d := p.Error(starts[i], "Incompatible type for argument %d of %s: expected %s, but got %s",
+				i+1, exp.Name, args[i].Type, param.Type())
+			d.Code = diag.Type
+			d.Notef(args[i].Token().Pos(), "parameter declared here")
+			p.report(d)
+		}
+	}
 }
 
 func (p *Parser) prepareLocals(locals []*ast.LocalVariable) {
 	for _, local := range locals {
 		if local.IsLocal {
-			if _, exists := p.curFn.Offsets[local.Name]; exists {
-				p.tzer.Error(p.cur, "Local variable already declared: %s", p.cur.Str)
+			// Shadowing a name of an outer scope is fine.
+			if prev, exists := p.scope.vars[local.Name]; exists {
+				d := p.Error(local.Token(), "Local variable already declared: %s", local.Name)
+				d.Code = diag.Redefined
+				d.Notef(prev.Token().Pos(), "previous declaration is here")
+				panic(d)
 			}
 
-			p.curFn.OffsetCnt += local.Type.StackSize()
-			p.curFn.Offsets[local.Name] = p.curFn.OffsetCnt
-			p.curFn.Locals[local.Name] = local
+			p.curFn.Alloc(local)
+			p.scope.vars[local.Name] = local
 		} else {
-			if _, exists := p.Globals[local.Name]; exists {
-				p.tzer.Error(p.cur, "Global variable already declared: %s", p.cur.Str)
+			if prev, exists := p.Globals[local.Name]; exists {
+				d := p.Error(local.Token(), "Global variable already declared: %s", local.Name)
+				d.Code = diag.Redefined
+				d.Notef(prev.Token().Pos(), "previous definition is here")
+				panic(d)
 			}
 
 			p.Globals[local.Name] = local
@@ -737,11 +2262,15 @@ func (p *Parser) prepareLocals(locals []*ast.LocalVariable) {
 }
 
 func (p *Parser) getLbl() string {
-	return fmt.Sprintf(".L.string.%d", p.strCnt)
+	lbl := fmt.Sprintf(".L.string.%d", p.strCnt)
+	p.strCnt++
+	return lbl
 }
 
 func (p *Parser) expect(token *token.Token, kinds ...token.TokenKind) {
-	p.tzer.Expect(token, kinds...)
+	if err := p.tzer.Expect(token, kinds...); err != nil {
+		panic(err)
+	}
 }
 
 func debug(s string, args ...interface{}) {
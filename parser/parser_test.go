@@ -1,8 +1,11 @@
 package parser
 
 import (
+	"fmt"
 	"go9cc/ast"
+	"go9cc/diag"
 	"go9cc/token"
+	"go9cc/types"
 	"testing"
 )
 
@@ -19,6 +22,10 @@ func TestParseInfix(t *testing.T) {
 			"char a[6] = \"hello\"; int main() { return 0; }",
 			"char[6] a = \"hello\"; int main () { return 0; }",
 		},
+		{
+			"int a[] = {1, 2}; char s[] = \"abc\"; int main() { return 0; }",
+			"int[2] a = {1, 2}; char[4] s = \"abc\"; int main () { return 0; }",
+		},
 		{
 			"char a; int main() { char x = 1; return 0; }",
 			"char a; int main () { char x = 1; return 0; }",
@@ -103,6 +110,26 @@ func TestParseInfix(t *testing.T) {
 			"int main () { int a; while (a == 10) return a; }",
 			"int main () { int a; while ((a == 10)) return a; }",
 		},
+		{
+			"int main () { int a; do a = a + 1; while (a < 10); do { a--; } while (a); }",
+			"int main () { int a; do (a = (a + 1)); while ((a < 10)); do { (a--); } while (a); }",
+		},
+		{
+			"int main () { int a; for (;;) { if (a) break; continue; } goto end; end: return a; }",
+			"int main () { int a; for (;;) { if (a) break; continue; } goto end; end: return a; }",
+		},
+		{
+			"int main () { int a; switch (a) { case 1: case 2: a = 3; break; default: return a; } }",
+			"int main () { int a; switch (a) { case 1: case 2: (a = 3); break; default: return a; } }",
+		},
+		{
+			"void f(void) { return; } void *g(void *p) { return p; } int main () { f(); }",
+			"void f () { return; } void* g (void* p) { return p; } int main () { f(); }",
+		},
+		{
+			"int main () { for (int i = 0;;) { int i; } for (int i = 0;;) i; { int a; { int a; } } }",
+			"int main () { for (int i = 0;;;) { int i; } for (int i = 0;;;) i; { int a; { int a; } } }",
+		},
 		{
 			"int main () { int a = 10; for (int i=0; i<10;i = i + 1) a = a + 3; }",
 			"int main () { int a = 10; for (int i = 0;;(i < 10);(i = (i + 1))) (a = (a + 3)); }",
@@ -120,9 +147,29 @@ func TestParseInfix(t *testing.T) {
 			"int foo () {  } int main () { foo(); }",
 		},
 		{
-			"int main () { int a; --a; }",
+			"int main () { int a; - -a; }",
 			"int main () { int a; (-(-a)); }",
 		},
+		{
+			"int main () { int a, b, c; a || b && c == 1 || !!c; }",
+			"int main () { int a, int b, int c; ((a || (b && (c == 1))) || (!(!c))); }",
+		},
+		{
+			"int main () { 1 | 2 ^ 3 & 4 == 5 << 6 + 7 % ~8 || 9; }",
+			"int main () { ((1 | (2 ^ (3 & (4 == (5 << (6 + (7 % (~8)))))))) || 9); }",
+		},
+		{
+			"int main () { int a, *p; a += -a++ * --a; *p++ <<= 2; ++*p; }",
+			"int main () { int a, int* p; (a += ((-(a++)) * (--a))); ((*(p++)) <<= 2); (++(*p)); }",
+		},
+		{
+			"int main () { 1 << 2 >> 3 % 4 * 5; }",
+			"int main () { ((1 << 2) >> ((3 % 4) * 5)); }",
+		},
+		{
+			"int main () { int a, b; a = b && !a; }",
+			"int main () { int a, int b; (a = (b && (!a))); }",
+		},
 		{
 			"int main () { int a; &*a; }",
 			"int main () { int a; (&(*a)); }",
@@ -159,17 +206,410 @@ func TestParseInfix(t *testing.T) {
 			"int main() { int x; sizeof x * 4; }",
 			"int main () { int x; ((sizeofx) * 4); }",
 		},
+		{
+			"static int x; static int f() { return x; } int main() { return f(); }",
+			"static int x; static int f () { return x; } int main () { return f(); }",
+		},
+		{
+			"int main() { int a; int b; a = a ? b = 1 : 2 ? 3 : 4; }",
+			"int main () { int a; int b; (a = (a ? (b = 1) : (2 ? 3 : 4))); }",
+		},
+		{
+			"int main() { int a; int b; a = 1, b = 2, a + b; }",
+			"int main () { int a; int b; (((a = 1), (b = 2)), (a + b)); }",
+		},
+		{
+			"int f(int a, int b) { return a; } int main() { return f((1, 2), 3); }",
+			"int f (int a, int b) { return a; } int main () { return f((1, 2), 3); }",
+		},
+		{
+			"struct P { int x; int y; }; int main() { struct P p; struct P *q = &p; q->y = p.x; return q[0].y; }",
+			"int main () { struct P p; struct P* q = (&p); (q->y = p.x); return (*(q + 0)).y; }",
+		},
+		{
+			"int main() { int a[2]; int *p = a; return p[1]; }",
+			"int main () { int[2] a; int* p = a; return (*(p + 1)); }",
+		},
+		{
+			"typedef int T; int main() { int x; T * y; { int T; T * x; } return 0; }",
+			"int main () { int x; int* y; { int T; (T * x); } return 0; }",
+		},
+		{
+			"int f(int a[2], int b[]) { return a[1]; } int main() { int x[2]; return f(x, x); }",
+			"int f (int* a, int* b) { return (*(a + 1)); } int main () { int[2] x; return f(x, x); }",
+		},
+		{
+			"unsigned char u[] = \"ab\"; signed char s[4] = \"ab\"; int main() { unsigned char t[] = \"cd\"; return 0; }",
+			"unsigned char[3] u = \"ab\"; char[4] s = \"ab\"; int main () { unsigned char[3] t = \"cd\"; return 0; }",
+		},
+		{
+			"enum E { A, B = 4, C }; int a[C] = {A, B}; int main() { return 0; }",
+			"int[5] a = {0, 4}; int main () { return 0; }",
+		},
+		{
+			"enum { A = 1 < 2, B = A == 1, C = !B + (A > 0u && B != 2) }; int a[C + 1] = {A, B}; int main() { return 0; }",
+			"int[2] a = {1, 1}; int main () { return 0; }",
+		},
 	}
 
 	for i, tt := range tests {
 		tzer := token.New(tt.input)
 		p := New(tzer)
-		node := p.Parse()
+		node, err := p.Parse()
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
 		testNode(t, i, node, tt.want)
 	}
 
 }
 
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		input string
+		msg   string
+		code  string
+	}{
+		{"int main() { return x; }", "Ident x not defined.", diag.Undefined},
+		{"int main() { int a; int a; }", "Local variable already declared: a", diag.Redefined},
+		{"int main() { int a[0]; }", "a positive number is expected. got 0.", diag.Syntax},
+		{"int main() { 1 + ; }", "Invalid token as primary: ;", diag.Syntax},
+		{"int main() { return 0 }", "Expected ;. Got }.", diag.Syntax},
+		{"int main() { return \"abc; }", "string not ended", diag.Lex},
+		{"int main() { int *a; a * 2; }", "Cannot mul/div: (a * 2)", diag.Type},
+		{"int f() { return 0; } int f() { return 1; }", "Function already defined: f", diag.Redefined},
+		{"int a[];", "Array size missing in a.", diag.Syntax},
+		{"int main() { int *a; a % 2; }", "Cannot mod: (a % 2)", diag.Type},
+		{"int main() { int *a; a & 2; }", "Cannot and/or/xor: (a & 2)", diag.Type},
+		{"int main() { int *a; 1 << a; }", "Cannot shift: (1 << a)", diag.Type},
+		{"int main() { int *a; ~a; }", "Cannot complement: (~a)", diag.Type},
+		{"int main() { int *p; int x = -p; }", "Cannot apply unary -: (-p)", diag.Type},
+		{"int main() { int *p; int x = +p; }", "Cannot apply unary +: (+p)", diag.Type},
+		{"int main() { int a[2]; -a; }", "Cannot apply unary -: (-a)", diag.Type},
+		{"struct S { int a; } s; int main() { +s; }", "Cannot apply unary +: (+s)", diag.Type},
+		{"int main() { 1++; }", "lvalue required as increment operand", diag.Type},
+		{"int main() { int a; --(a + 1); }", "lvalue required as decrement operand", diag.Type},
+		{"int main() { int a[2]; ++a; }", "Cannot increment/decrement: a", diag.Type},
+		{"int main() { int a; (a + 1) += 2; }", "lvalue required as left operand of assignment", diag.Type},
+		{"int main() { int a[2]; a += 2; }", "Cannot assign to a", diag.Type},
+		{"int main() { 3 = 4; }", "lvalue required as left operand of assignment", diag.Type},
+		{"int main() { int a; (a + 1) = 4; }", "lvalue required as left operand of assignment", diag.Type},
+		{"int main() { int x; int *p = &(x + 1); }", "lvalue required as unary '&' operand", diag.Type},
+		{"int main() { int *a; a *= 2; }", "Cannot mul/div: (a * 2)", diag.Type},
+		{"int a = 9223372036854775808;", "Integer literal 9223372036854775808 is too large to be represented in any integer type", diag.Syntax},
+		{"int a[2] = {1, 2, 3};", "Excess elements in array initializer of int[2] a", diag.Type},
+		{"char s[2] = \"abc\";", "Initializer string is too long for char[2] s", diag.Type},
+		{"unsigned char s[2] = \"abc\";", "Initializer string is too long for unsigned char[2] s", diag.Type},
+		{"int a[4] = \"abc\";", "Type mismatch: int[4] a", diag.Type},
+		{"int a[2] = {1, \"b\"};", "Type mismatch: char[2] for element of int[2] a", diag.Type},
+		{"int main() { int *a; 1 ? a : 2; }", "Type mismatch in conditional expression: int* and int", diag.Type},
+		{"int main() { 1 ? 2; }", "Expected :. Got ;.", diag.Syntax},
+		{"int main() { do {} while (1) }", "Expected ;. Got }.", diag.Syntax},
+		{"int main() { break; }", "break statement not within loop or switch", diag.Syntax},
+		{"int main() { if (1) continue; }", "continue statement not within a loop", diag.Syntax},
+		{"int main() { goto end; }", "Label end not defined.", diag.Undefined},
+		{"int main() { a: a: return 0; }", "Label already defined: a", diag.Redefined},
+		{"int main() { { int a; } return a; }", "Ident a not defined.", diag.Undefined},
+		{"int main() { for (int i = 0;;) {} return i; }", "Ident i not defined.", diag.Undefined},
+		{"int f(int a) { int a; return a; }", "Local variable already declared: a", diag.Redefined},
+		{"void x;", "Variable x declared void", diag.Syntax},
+		{"void f(void a) { }", "Parameter a declared void", diag.Syntax},
+		{"int main() { void a[2]; }", "Declaration of a as array of voids", diag.Syntax},
+		{"void f() { return 1; }", "Void function f should not return a value", diag.Syntax},
+		{"int f() { return; }", "Non-void function f should return a value", diag.Syntax},
+		{"void f() { } int main() { return f(); }", "Void value not ignored as it ought to be", diag.Type},
+		{"void f() { } int main() { int a = f(); }", "Void value not ignored as it ought to be", diag.Type},
+		{"void f() { } int main() { 1 + f(); }", "Void value not ignored as it ought to be", diag.Type},
+		{"void f() { } int main() { if (f()) return 0; }", "Void value not ignored as it ought to be", diag.Type},
+		{"void f() { } int g(int a) { return a; } int main() { g(f()); }", "Void value not ignored as it ought to be", diag.Type},
+		{"int main() { int a; void *p = &a; *p + 1; }", "Void value not ignored as it ought to be", diag.Type},
+		{"int main() { int a; char *p = &a; }", "Type mismatch: char* p", diag.Type},
+		{"int main() { case 1: return 0; }", "case label not within a switch statement", diag.Syntax},
+		{"int main() { switch (1) { default: default: return 0; } }", "Multiple default labels in one switch", diag.Redefined},
+		{"int main() { switch (1) { case 1: case 2: case 1: return 0; } }", "Duplicate case value: 1", diag.Redefined},
+		{"int main() { switch (1) { case 'a': case 97: return 0; } }", "Duplicate case value: 97", diag.Redefined},
+		{"int main() { switch (1) { case 1: switch (2) { case 1: break; } case 1: return 0; } }", "Duplicate case value: 1", diag.Redefined},
+		{"int main() { int *p; switch (p) {} }", "Switch quantity is not an integer: p", diag.Type},
+		{"int main() { switch (1) { continue; } }", "continue statement not within a loop", diag.Syntax},
+		{"long char x;", "Invalid type long char", diag.Syntax},
+		{"signed unsigned x;", "Invalid type signed unsigned", diag.Syntax},
+		{"long long long x;", "Invalid type long long long", diag.Syntax},
+		{"int f(short int int a) { return a; }", "Invalid type short int int", diag.Syntax},
+		{"unsigned void f() { }", "Invalid type unsigned void", diag.Syntax},
+		{"struct S { int a; }; struct S { int b; };", "Redefinition of struct S", diag.Redefined},
+		{"struct S { int a; char a; };", "Duplicate member a", diag.Redefined},
+		{"struct S { void v; };", "Field v declared void", diag.Syntax},
+		{"struct S { struct S s; };", "Field s has incomplete type", diag.Syntax},
+		{"struct S; struct S s;", "Storage size of s isn't known", diag.Syntax},
+		{"struct S *p; int main() { return sizeof(*p); }", "Invalid application of sizeof to incomplete type struct S", diag.Type},
+		{"struct S; int main() { return sizeof(struct S); }", "Invalid application of sizeof to incomplete type struct S", diag.Type},
+		{"int main() { return sizeof(void[2]); }", "Declaration of type name as array of voids", diag.Syntax},
+		{"struct S *p; int main() { return p->a; }", "Dereferencing pointer to incomplete type struct S", diag.Syntax},
+		{"int main() { int a; return a.x; }", "Request for member x in something not a structure or union: a", diag.Syntax},
+		{"int main() { int a; return a->x; }", "Invalid type argument of ->: int", diag.Syntax},
+		{"struct S { int a; }; int main() { struct S s; return s.b; }", "struct S has no member named b", diag.Syntax},
+		{"int main() { int a; return a[0]; }", "Subscripted value is neither array nor pointer: a", diag.Syntax},
+		{"struct S { int a; }; int f(struct S s) { return 0; }", "Passing struct S by value is not supported: s", diag.Syntax},
+		{"struct S { int a; }; int main() { struct S s = {1, 2}; }", "Excess elements in struct initializer of struct S s", diag.Type},
+		{"union U { int a; char b; }; int main() { union U u = {1, 2}; }", "Excess elements in union initializer of union U u", diag.Type},
+		{"struct S { int a; }; union S u;", "S defined as wrong kind of tag", diag.Syntax},
+		{"union U { int a; }; union U { int a; };", "Redefinition of union U", diag.Redefined},
+		{"enum E { A, B, A };", "Redeclaration of A", diag.Redefined},
+		{"int A; enum E { A };", "Redeclaration of A", diag.Redefined},
+		{"enum E { A }; enum E { B };", "Redefinition of enum E", diag.Redefined},
+		{"enum E e;", "Use of undefined enum E", diag.Undefined},
+		{"struct S { int a; }; enum S e;", "S defined as wrong kind of tag", diag.Syntax},
+		{"int x; enum E { A = x };", "Enumerator value for A is not an integer constant", diag.Syntax},
+		{"enum { X = 2147483648 };", "Enumerator value for X is out of range of int", diag.Syntax},
+		{"enum { X = -2147483649 };", "Enumerator value for X is out of range of int", diag.Syntax},
+		{"enum { X = 2147483647, Y };", "Enumerator value for Y is out of range of int", diag.Syntax},
+		{"enum { X = (1 < 2) + 2147483647L };", "Enumerator value for X is out of range of int", diag.Syntax},
+		{"enum { X }; int main() { X = 4; }", "lvalue required as left operand of assignment", diag.Type},
+		{"enum { X }; int main() { int *p = &X; }", "lvalue required as unary '&' operand", diag.Type},
+		{"enum { X }; int main() { X++; }", "lvalue required as increment operand", diag.Type},
+		{"int main() { int n = 2; int a[n]; }", "Array size of a is not an integer constant", diag.Syntax},
+		{"enum E { A = 1 }; int a[A - 2];", "a positive number is expected. got -1.", diag.Syntax},
+		{"typedef int T; int main() { return T; }", "Unexpected type name T", diag.Syntax},
+		{"typedef int T; typedef char T;", "Conflicting types for T", diag.Redefined},
+		{"int T; typedef int T;", "Redeclaration of T", diag.Redefined},
+		{"Foo x;", "Unknown type name Foo", diag.Syntax},
+		{"int f(Foo x) { return 0; }", "Unknown type name Foo", diag.Syntax},
+		{"int f(int); int f(char a) { return 0; }", "Conflicting types for f", diag.Redefined},
+		{"int f(int); char f(int);", "Conflicting types for f", diag.Redefined},
+		{"int f(int, int); int f(int a) { return a; }", "Conflicting types for f", diag.Redefined},
+		{"int f(); int f() { return 0; } int f() { return 1; }", "Function already defined: f", diag.Redefined},
+		{"int f(int) { return 0; }", "Parameter name omitted", diag.Syntax},
+		{"int f(int, void);", "void must be the only parameter", diag.Syntax},
+		{"int f(int a) { return a; } int main() { return f(1, 2); }", "Too many arguments to function f", diag.Type},
+		{"int f(int a, int b) { return a; } int main() { return f(1); }", "Too few arguments to function f", diag.Type},
+		{"int f(void); int main() { return f(1); }", "Too many arguments to function f", diag.Type},
+		{"int f() { return 0; } int main() { return f(1); }", "Too many arguments to function f", diag.Type},
+		{"int f(int a, ...); int main() { return f(); }", "Too few arguments to function f", diag.Type},
+		{"int f(char *s, ...); int main() { return f(1, 2); }", "Incompatible type for argument 1 of f: expected char*, but got int", diag.Type},
+		{"int f(int a, ...); int f(int a) { return a; }", "Conflicting types for f", diag.Redefined},
+		{"int f(); char f(int a);", "Conflicting types for f", diag.Redefined},
+		{"struct { int a; } **p; struct { int a; } **q; int main() { p = q; }", "Cannot assign: (p = q)", diag.Type},
+		{"int main() { struct S { int a; } **r; { struct S { int a; } **t; r = t; } }", "Cannot assign: (r = t)", diag.Type},
+		{"typedef struct { int a; } A[2]; typedef struct { int a; } B[2]; A *p; B *q; int main() { p = q; }", "Cannot assign: (p = q)", diag.Type},
+		{"int f(struct { int a; } **p); int f(struct { int a; } **p);", "Conflicting types for f", diag.Redefined},
+		{"int f(...);", "A named parameter is required before ...", diag.Syntax},
+		{"int f(int *p); int main() { int a; return f(a); }", "Incompatible type for argument 1 of f: expected int*, but got int", diag.Type},
+		{"int f(int a, char *s); int main() { int a; return f(1, &a); }", "Incompatible type for argument 2 of f: expected char*, but got int*", diag.Type},
+	}
+
+	for i, tt := range tests {
+		tzer := token.New(tt.input)
+		p := New(tzer)
+		node, err := p.Parse()
+		if node != nil {
+			t.Errorf("%d: node must be nil on error, but got=%s", i, node)
+		}
+
+		ds, ok := err.(diag.List)
+		if !ok || len(ds) != 1 {
+			t.Fatalf("%d: one diagnostic expected, but got=%T (%v)", i, err, err)
+		}
+
+		d := ds[0]
+
+		if d.Message != tt.msg {
+			t.Errorf("%d: wrong msg: got=%s, want=%s", i, d.Message, tt.msg)
+		}
+
+		if d.Code != tt.code {
+			t.Errorf("%d: wrong code: got=%s, want=%s", i, d.Code, tt.code)
+		}
+	}
+}
+
+func TestParseRecovery(t *testing.T) {
+	input := `
+int x = ;
+int f(int a) {
+  int b = a +;
+  if (a == ) { b = 1; }
+  for (;;) { return 0 }
+  return a * b;
+}
+int main() {
+  char *s;
+  s * 2;
+  switch (1) { case 1: case 1: break; }
+  return y;
+}
+`
+	want := []struct {
+		line int
+		msg  string
+	}{
+		{2, "Invalid token as primary: ;"},
+		{4, "Invalid token as primary: ;"},
+		{5, "Invalid token as primary: )"},
+		{6, "Expected ;. Got }."},
+		{11, "Cannot mul/div: (s * 2)"},
+		{12, "Duplicate case value: 1"},
+		{13, "Ident y not defined."},
+	}
+
+	p := New(token.New(input))
+	_, err := p.Parse()
+	ds := diag.AsList(err)
+	if len(ds) != len(want) {
+		t.Fatalf("%d errors expected, but got=%d:\n%s", len(want), len(ds), ds)
+	}
+
+	for i, w := range want {
+		if ds[i].Pos.Line() != w.line || ds[i].Message != w.msg {
+			t.Errorf("%d: want=%d: %s, but got=%d: %s", i, w.line, w.msg, ds[i].Pos.Line(), ds[i].Message)
+		}
+	}
+}
+
+func TestGlobalRedeclaration(t *testing.T) {
+	tests := []struct {
+		input   string
+		col     int // of the error
+		prevCol int // of the note
+	}{
+		{"int x; int x;", 12, 5},
+		{"int x = 1; int y, x = 2;", 19, 5},
+		{"char *s;\nint a, s[2];", 8, 7},
+	}
+
+	for i, tt := range tests {
+		p := New(token.New(tt.input))
+		_, err := p.Parse()
+		ds := diag.AsList(err)
+		if len(ds) != 1 || ds[0].Code != diag.Redefined {
+			t.Fatalf("%d: one redefinition expected, but got=%d:\n%s", i, len(ds), ds)
+		}
+
+		if ds[0].Pos.Column() != tt.col {
+			t.Errorf("%d: want column %d, but got=%s", i, tt.col, ds[0])
+		}
+
+		if len(ds[0].Notes) != 1 || ds[0].Notes[0].Pos.Column() != tt.prevCol || ds[0].Notes[0].Message != "previous definition is here" {
+			t.Errorf("%d: a note at column %d is expected, but got=%v", i, tt.prevCol, ds[0].Notes)
+		}
+	}
+}
+
+func TestCheckArgs(t *testing.T) {
+	tests := []struct {
+		input string
+		col   int // of the error
+	}{
+		{"int f(int a); int main() { return f(1, 2 + 3); }", 40},
+		{"int f(int a, int b); int main() { return f(1 ); }", 46},
+		{"int f(int *p); int main() { return f(1); }", 38},
+		{"int f(int a, int *p); int main() { int a; return f(a, a + 1); }", 55},
+		{"int f(char *s, ...); int main() { return f(1, 2); }", 44},
+		{"int f(int a, int b, ...); int main() { return f(1); }", 50},
+	}
+
+	for i, tt := range tests {
+		p := New(token.New(tt.input))
+		_, err := p.Parse()
+		ds := diag.AsList(err)
+		if len(ds) != 1 {
+			t.Fatalf("%d: one diagnostic expected, but got=%d:\n%s", i, len(ds), ds)
+		}
+
+		if ds[0].Pos.Column() != tt.col {
+			t.Errorf("%d: want column %d, but got=%s", i, tt.col, ds[0])
+		}
+
+		if len(ds[0].Notes) != 1 {
+			t.Errorf("%d: a note on the declaration is expected", i)
+		}
+	}
+
+	// Conversions like assignments are fine, and the arguments for "..."
+	// or of a function without a prototype are not checked.
+	inputs := []string{
+		"int f(char c, long l, int *p); int main() { int a[2]; return f(300, 1, a) + f(1, 2, 0); }",
+		"int f(char *s, ...); int main() { int a[2]; return f(\"x\") + f(\"x\", 1, a, \"y\"); }",
+		"int f(); int main() { int a[2]; return f() + f(1, a); }",
+		"int f(); int f(int a) { return a; } int main() { return f(1); }",
+	}
+	for i, input := range inputs {
+		if _, err := New(token.New(input)).Parse(); err != nil {
+			t.Errorf("%d: %s", i, err)
+		}
+	}
+
+	// A prototype after a declaration without one is checked.
+	p := New(token.New("int f(); int f(int a); int main() { return f(1, 2); }"))
+	_, err := p.Parse()
+	ds := diag.AsList(err)
+	if len(ds) != 1 || ds[0].Message != "Too many arguments to function f" {
+		t.Errorf("too many arguments expected, but got:\n%s", ds)
+	}
+}
+
+func TestImplicitDeclaration(t *testing.T) {
+	input := `
+int g(int a);
+int main() {
+  f(1);
+  g(2);
+  f(3);
+  return h();
+}
+int h() { return 0; }
+`
+	want := []struct {
+		line int
+		msg  string
+	}{
+		{4, "Implicit declaration of function f"},
+		{7, "Implicit declaration of function h"},
+	}
+
+	p := New(token.New(input))
+	if _, err := p.Parse(); err != nil {
+		t.Fatalf("warnings must not fail the parse: %s", err)
+	}
+
+	ws := p.Warnings()
+	if len(ws) != len(want) {
+		t.Fatalf("%d warnings expected, but got=%d:\n%s", len(want), len(ws), ws)
+	}
+
+	for i, w := range want {
+		if ws[i].Severity != diag.Warning || ws[i].Pos.Line() != w.line || ws[i].Message != w.msg {
+			t.Errorf("%d: want=%d: %s, but got=%d: %s", i, w.line, w.msg, ws[i].Pos.Line(), ws[i])
+		}
+		if ws[i].Code != diag.ImplicitDecl {
+			t.Errorf("%d: code %s expected, but got=%s", i, diag.ImplicitDecl, ws[i].Code)
+		}
+	}
+
+	// The call fixed the return type to int.
+	p = New(token.New("int main() { return f(); } char f() { return 0; }"))
+	_, err := p.Parse()
+	ds := diag.AsList(err)
+	if len(ds) != 2 || ds[1].Message != "Conflicting types for f" {
+		t.Fatalf("a warning and a conflict expected, but got:\n%s", ds)
+	}
+}
+
+func TestParseErrorLimit(t *testing.T) {
+	input := "int main() { x; x; x; x; x; }"
+
+	p := New(token.New(input))
+	p.ErrorLimit = 3
+	_, err := p.Parse()
+	ds := diag.AsList(err)
+	if len(ds) != 4 {
+		t.Fatalf("3 errors and a note expected, but got=%d:\n%s", len(ds), ds)
+	}
+
+	if ds[3].Severity != diag.Note {
+		t.Errorf("last diagnostic must be a note, but got=%s", ds[3])
+	}
+}
+
 func testNode(t *testing.T, i int, node ast.Node, want string) {
 	if node == nil {
 		t.Fatalf("%d: Node is nil: want=%s", i, want)
@@ -179,3 +619,233 @@ func testNode(t *testing.T, i int, node ast.Node, want string) {
 		t.Fatalf("%d: Wrong Node:\ngot =%s,\nwant=%s", i, node.String(), want)
 	}
 }
+
+func TestNumType(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"2147483647", "int"},
+		{"2147483648", "long"},
+		{"0x7fffffff", "int"},
+		{"0x80000000", "unsigned int"},
+		{"0x100000000", "long"},
+		{"0x8000000000000000", "unsigned long"},
+		{"10u", "unsigned int"},
+		{"4294967296u", "unsigned long"},
+		{"10l", "long"},
+		{"10LL", "long"},
+		{"0xffffffffffffffffl", "unsigned long"},
+		{"10ul", "unsigned long"},
+		{"'a'", "int"},
+	}
+
+	for i, tt := range tests {
+		tzer := token.New(tt.input)
+		tkn, err := tzer.Tokenize()
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+
+		p := New(tzer)
+		got := p.numType(tkn).String()
+		if got != tt.want {
+			t.Errorf("%d: %s: got=%s, want=%s", i, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestDeclspec(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"char", "char"},
+		{"signed char", "char"},
+		{"unsigned char", "unsigned char"},
+		{"short", "short"},
+		{"short int", "short"},
+		{"unsigned short", "unsigned short"},
+		{"int short unsigned", "unsigned short"},
+		{"int", "int"},
+		{"signed", "int"},
+		{"unsigned", "unsigned int"},
+		{"unsigned int", "unsigned int"},
+		{"long", "long"},
+		{"long int", "long"},
+		{"long long", "long"},
+		{"signed long long int", "long"},
+		{"long unsigned", "unsigned long"},
+		{"unsigned long long", "unsigned long"},
+		{"void", "void"},
+	}
+
+	for i, tt := range tests {
+		// "void x" is an error, so the type is read through a pointer.
+		tzer := token.New(tt.input + " *x;")
+		p := New(tzer)
+		_, err := p.Parse()
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+
+		got := p.Globals["x"].Type.(*types.IntPointer).Base.String()
+		if got != tt.want {
+			t.Errorf("%d: %s: got=%s, want=%s", i, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestArithType(t *testing.T) {
+	tests := []struct {
+		exp  string
+		want string
+	}{
+		{"c + c", "int"},
+		{"s * c", "int"},
+		{"c + u", "unsigned int"},
+		{"i + u", "unsigned int"},
+		{"u + l", "long"},
+		{"i - ul", "unsigned long"},
+		{"l / ul", "unsigned long"},
+		{"c << l", "int"},
+		{"u >> i", "unsigned int"},
+		{"i < u", "int"},
+		{"p + l", "int*"},
+		{"c = l", "char"},
+		{"s += l", "short"},
+	}
+
+	for i, tt := range tests {
+		input := "int main() { char c; short s; int i; unsigned u; long l; unsigned long ul; int *p; " + tt.exp + "; }"
+		tzer := token.New(input)
+		p := New(tzer)
+		node, err := p.Parse()
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+
+		stmts := node.FuncDefs[0].Body.Stmts.Stmts
+		exp := stmts[len(stmts)-1].(*ast.ExpStmt).Exp
+		if got := exp.Type().String(); got != tt.want {
+			t.Errorf("%d: %s: got=%s, want=%s", i, tt.exp, got, tt.want)
+		}
+	}
+}
+
+func TestStructLayout(t *testing.T) {
+	tests := []struct {
+		input   string
+		size    int
+		offsets []int
+	}{
+		{"struct { char a; int b; char c; } x;", 12, []int{0, 4, 8}},
+		{"struct { char a; long b; short c; } x;", 24, []int{0, 8, 16}},
+		{"struct { char a; char b[3]; short c; } x;", 6, []int{0, 1, 4}},
+		{"struct { int a; struct { char b; long c; } d; char e; } x;", 32, []int{0, 8, 24}},
+		{"struct { char a; int *p; } x;", 16, []int{0, 8}},
+		{"union { char a; int b; short c[3]; } x;", 8, []int{0, 0, 0}},
+		{"union { char a[5]; long b; } x;", 8, []int{0, 0}},
+		{"struct { char a; union { char b; short c; } u; } x;", 4, []int{0, 2}},
+	}
+
+	for i, tt := range tests {
+		tzer := token.New(tt.input)
+		p := New(tzer)
+		if _, err := p.Parse(); err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+
+		st := p.Globals["x"].Type.(*types.Struct)
+		if st.Size() != tt.size {
+			t.Errorf("%d: wrong size: got=%d, want=%d", i, st.Size(), tt.size)
+		}
+
+		for j, m := range st.Members {
+			if m.Offset != tt.offsets[j] {
+				t.Errorf("%d: wrong offset of %s: got=%d, want=%d", i, m.Name, m.Offset, tt.offsets[j])
+			}
+		}
+	}
+}
+
+func TestSizeofType(t *testing.T) {
+	tests := []struct {
+		typename string
+		want     int
+	}{
+		{"char", 1},
+		{"unsigned short", 2},
+		{"int", 4},
+		{"long", 8},
+		{"int *", 8},
+		{"char *[4]", 32},
+		{"int[3]", 12},
+		{"struct {char a; int b; long c; char d;}", 24},
+		{"union {char c[5]; int i;}", 8},
+		{"enum {A, B}", 4},
+	}
+
+	for i, tt := range tests {
+		input := "int main() { return sizeof(" + tt.typename + "); }"
+		node, err := New(token.New(input)).Parse()
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+		testNode(t, i, node, fmt.Sprintf("int main () { return %d; }", tt.want))
+	}
+}
+
+func TestSizeofTypedef(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{
+			"typedef struct { char a; long b; } S; int main() { return sizeof(S) + sizeof(S *); }",
+			"int main () { return (16 + 8); }",
+		},
+		{
+			"typedef int A[3]; int main() { return sizeof(A); }",
+			"int main () { return 12; }",
+		},
+		{
+			"typedef long T; int main() { char T; return sizeof(T); }",
+			"int main () { char T; return (sizeofT); }",
+		},
+	}
+
+	for i, tt := range tests {
+		node, err := New(token.New(tt.input)).Parse()
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+		testNode(t, i, node, tt.want)
+	}
+}
+
+func TestStackSlots(t *testing.T) {
+	input := "int main() { int x; { int a[8]; } { int b[8]; } for (int i;;) { int c; } return 0; }"
+	tzer := token.New(input)
+	p := New(tzer)
+	node, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse: %s", err)
+	}
+
+	fn := node.FuncDefs[0]
+	if fn.StackSize != 48 {
+		t.Errorf("wrong stack size: got=%d, want=48", fn.StackSize)
+	}
+
+	stmts := fn.Body.Stmts.Stmts
+	offset := func(i int) int {
+		block := stmts[i].(*ast.BlockStmt)
+		list := block.Stmts.Stmts[0].(*ast.StmtListNode)
+		return list.Stmts[0].(*ast.DeclarationStmt).Target().Offset
+	}
+
+	if offset(1) != 36 || offset(2) != 36 {
+		t.Errorf("a and b must share a slot: got=%d, %d, want=36", offset(1), offset(2))
+	}
+}
@@ -31,7 +31,12 @@ func (r *Repl) Run(in io.Reader, out io.Writer) {
 
 		tzer := token.New(line)
 		p := parser.New(tzer)
-		node := p.Parse()
+		node, err := p.Parse()
+		if err != nil {
+			io.WriteString(out, err.Error())
+			io.WriteString(out, "\n")
+			continue
+		}
 
 		io.WriteString(out, node.String())
 		io.WriteString(out, "\n")
@@ -1,16 +1,14 @@
 #!/bin/bash
-err="./logs/build.log"
 
 FILES=`find testcases -name '*.c'`
 for f in $FILES; do
   echo "$f"
-  timeout 3 ./main "$f" 1> tmp.s 2>>$err
+  timeout 3 ./main -o tmp "$f" hello.o test.o
   if [[ "$?" != "0" ]]; then
-    echo "Error while compiling. Check out $err."
+    echo "Error while compiling."
     exit 1
   fi
 
-  cc -o tmp tmp.s hello.o test.o
   ./tmp
   if [[ "$?" != "0" ]]; then
     echo "FAIL"
@@ -19,4 +17,3 @@ for f in $FILES; do
 done
 
 echo PASS
-
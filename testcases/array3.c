@@ -0,0 +1,31 @@
+int second(int a[2]) {
+  return a[1];
+}
+
+int sum(int a[], int n) {
+  int s = 0;
+  for (int i = 0; i < n; i++)
+    s = s + a[i];
+  return s;
+}
+
+int fill(char s[], int n) {
+  for (int i = 0; i < n; i++)
+    s[i] = 'a' + i;
+  return sizeof(s);
+}
+
+int main() {
+  int arr[2] = {3, 4};
+  assert(second(arr), 4);
+
+  int nums[4] = {1, 2, 3, 4};
+  assert(sum(nums, 4), 10);
+  assert(sum(nums + 1, 2), 5);
+
+  char buf[3];
+  assert(fill(buf, 3), 8);
+  assert(buf[0], 'a');
+  assert(buf[2], 'c');
+  return 0;
+}
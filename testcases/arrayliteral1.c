@@ -1,5 +1,21 @@
+// dirty leaves non-zero values in the stack used by the next call.
+int dirty() {
+  long junk[2];
+  junk[0] = -1;
+  junk[1] = -1;
+  return 0;
+}
+
+int partialLast() {
+  int a[4] = {1};
+  return a[3];
+}
+
 int main() {
   int arr[3] = {10, 20, 30};
   assert(arr[0] + arr[2], 40);
+
+  dirty();
+  assert(partialLast(), 0);
   return 0;
 }
@@ -0,0 +1,30 @@
+int g = (1 << 4) | 3 ^ 1;
+int m = 17 % 5;
+
+int main() {
+  assert(17 % 5, 2);
+  assert(20 % 4, 0);
+  int a = 13;
+  int b = 4;
+  assert(a % b, 1);
+  assert(a / b * b + a % b, a);
+  assert(12 & 10, 8);
+  assert(12 | 10, 14);
+  assert(12 ^ 10, 6);
+  assert(~0, -1);
+  assert(~5, -6);
+  assert(1 << 4, 16);
+  assert(a << b, 208);
+  assert(256 >> 4, 16);
+  assert(-16 >> 2, -4);
+  assert(a >> 1, 6);
+  assert(0x80000000u >> 31, 1);
+  assert(1 | 2 & 3 ^ 4, 7);
+  assert(1 + 2 << 3, 24);
+  assert(3 & 1 == 1, 1);
+  assert(g, 18);
+  assert(m, 2);
+  char c = 3;
+  assert(c << 6, 192);
+  return 0;
+}
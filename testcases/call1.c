@@ -0,0 +1,57 @@
+int assert(int got, int want);
+int assertS(char *got, char *want, int len);
+int sprintf(char *buf, char *fmt, ...);
+int later();
+
+int plus(int a, int b) {
+  return a + b;
+}
+
+int digits(int a, int b, int c) {
+  return a * 100 + b * 10 + c;
+}
+
+int narrow(char c) {
+  return c;
+}
+
+long widen(long x) {
+  return x;
+}
+
+int first(int *p) {
+  if (p == 0)
+    return -1;
+  return *p;
+}
+
+int main() {
+  int arr[3] = {1, 2, 3};
+  int i = 1;
+
+  // Evaluating an argument must not clobber the ones before it.
+  assert(digits(plus(0, 1), plus(1, 1), plus(1, 2)), 123);
+  assert(digits(1, 4 / i / 2, 3), 123);
+  assert(digits(1, 2, 7 % 4), 123);
+  assert(digits(arr[0], arr[i], arr[2]), 123);
+
+  // Arguments are converted to the types of the parameters.
+  assert(narrow(258), 2);
+  assert(narrow(-1), -1);
+  assert(widen(-1) < 0, 1);
+  assert(widen(i) == 1, 1);
+  assert(first(arr), 1);
+  assert(first(0), -1);
+
+  // The arguments for ... and of a function without a prototype are
+  // passed as they are.
+  char buf[16];
+  assert(sprintf(buf, "%d%c", narrow(258), 'z'), 2);
+  assertS(buf, "2z", 3);
+  assert(later(plus(1, 2), 4), 7);
+  return 0;
+}
+
+int later(int a, int b) {
+  return a + b;
+}
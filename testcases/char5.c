@@ -0,0 +1,27 @@
+char s[] = "a\"b\\c\n\t\101\x42\0d";
+char *q = "it's";
+
+int main() {
+  char c = 'a';
+  assertC(c, 97);
+  assert('\n', 10);
+  assert('\0', 0);
+  assert('\'', 39);
+  assert('\\', 92);
+  assert('\101', 65);
+  assert('\x41', 65);
+  assert('\xff', -1);
+  assert('"', 34);
+  assert(sizeof(s), 12);
+  assertC(s[1], '"');
+  assertC(s[3], '\\');
+  assertC(s[5], '\n');
+  assertC(s[6], '\t');
+  assertC(s[7], 'A');
+  assertC(s[8], 'B');
+  assertC(s[9], 0);
+  assertC(s[10], 'd');
+  assertS(q, "it's", 5);
+  assertS("x\ty", "x\ty", 4);
+  return 0;
+}
@@ -0,0 +1,48 @@
+int x = 1 ? 2 : 3;
+int y = 0 ? 2 : 1 + 2;
+int a[3] = {1, 2, 3};
+int *p = 1 ? a + 1 : a;
+int *q = 0 ? a : 0;
+int z = 1 < 2 ? 2 : 3;
+int b[2 == 2 ? 3 : 1];
+int n = !0;
+int u = -1 < 1u || 0 && 1 / 0;
+
+int max(int a, int b) {
+  return a > b ? a : b;
+}
+
+int main() {
+  assert(x, 2);
+  assert(y, 3);
+  assert(*p, 2);
+  assert(q == 0, 1);
+  assert(z, 2);
+  assert(sizeof(b), 12);
+  assert(n, 1);
+  assert(u, 0);
+
+  assert(max(3, 5), 5);
+  assert(max(7, 5), 7);
+
+  int i = 0;
+  int c = 0;
+  assert(i ? c = 1 : 2, 2);
+  assert(c, 0);
+  assert(1 ? 2 : 0 ? 3 : 4, 2);
+  assert(0 ? 2 : 0 ? 3 : 4, 4);
+
+  int *r = i ? a : a + 2;
+  assert(*r, 3);
+
+  int j;
+  int sum = 0;
+  for (i = 0, j = 10; i < j; i++, j--) {
+    sum = sum + 1;
+  }
+  assert(sum, 5);
+  assert((i = 3, i + 1), 4);
+  assert(i, 3);
+
+  return 0;
+}
@@ -0,0 +1,23 @@
+int main() {
+  int a = 0;
+  do
+    a = a + 4;
+  while (a < 5);
+  assert(a, 8);
+
+  int b = 10;
+  do {
+    b = b + 1;
+  } while (0);
+  assert(b, 11);
+
+  int i = 0;
+  int sum = 0;
+  do {
+    sum += i;
+    i++;
+  } while (i < 5);
+  assert(sum, 10);
+
+  return 0;
+}
@@ -0,0 +1,65 @@
+enum Color { RED, GREEN = 5, BLUE };
+
+enum Size { SMALL = 2, LARGE = SMALL * 4, };
+
+enum Flag { ON = 1 < 2, OFF = ON == 0, BOTH = ON && !OFF };
+
+int gcolor = BLUE;
+int gsizes[LARGE];
+enum Color gfav = GREEN;
+
+enum Color next(enum Color c) {
+  switch (c) {
+  case RED:
+    return GREEN;
+  case GREEN:
+    return BLUE;
+  case BLUE:
+    return RED;
+  }
+  return -1;
+}
+
+int main() {
+  assert(RED, 0);
+  assert(GREEN, 5);
+  assert(BLUE, 6);
+  assert(SMALL, 2);
+  assert(LARGE, 8);
+  assert(ON, 1);
+  assert(OFF, 0);
+  assert(BOTH, 1);
+  assert(gcolor, 6);
+  assert(gfav, 5);
+  assert(sizeof(gsizes), 32);
+
+  enum Color c = RED;
+  assert(sizeof(c), 4);
+  c = next(c);
+  assert(c, GREEN);
+  assert(next(BLUE), RED);
+
+  int arr[BLUE - GREEN + 1];
+  assert(sizeof(arr), 8);
+  char buf[sizeof(c) * 2];
+  assert(sizeof(buf), 8);
+
+  enum { A = -1, B, C } x = C;
+  assert(A, -1);
+  assert(B, 0);
+  assert(x, 1);
+
+  {
+    enum { RED = 10 };
+    assert(RED, 10);
+    int BLUE = 3;
+    assert(BLUE, 3);
+  }
+  assert(RED, 0);
+  assert(BLUE, 6);
+
+  enum Color d;
+  d = BLUE;
+  assert(d == BLUE, 1);
+  return 0;
+}
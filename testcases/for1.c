@@ -1,5 +1,6 @@
 int main() {
-  for (int i = 0; i < 10; i = i + 4)
+  int i;
+  for (i = 0; i < 10; i = i + 4)
     4;
   assert(i, 12);
   return 0;
@@ -0,0 +1,41 @@
+int a[5] = {1, 2, 3};
+int b[] = {4, 5, 6, 7};
+int c[3];
+char s[8] = "abc";
+char t[] = "hello";
+char u[3] = "xyz";
+int x = 10;
+int y = 20;
+int *px = &x;
+int *pa = &a[1];
+int *pb = b + 2;
+int *pe = &a[0] + 4;
+char *str = "world";
+char *strs[3] = {"foo", "bar"};
+int *ptrs[2] = {&x, &y};
+int n, m = 3;
+
+int main() {
+  assert(a[0] + a[1] + a[2], 6);
+  assert(a[3] + a[4], 0);
+  assert(sizeof(b), 16);
+  assert(b[3], 7);
+  assert(c[0] + c[1] + c[2], 0);
+  assert(sizeof(s), 8);
+  assertS(s, "abc", 4);
+  assertC(s[7], 0);
+  assert(sizeof(t), 6);
+  assertS(t, "hello", 6);
+  assertC(u[2], 'z');
+  assert(*px, 10);
+  assert(*pa, 2);
+  assert(*pb, 6);
+  assert(*pe, 0);
+  assertS(str, "world", 6);
+  assertS(strs[1], "bar", 4);
+  assert(strs[2], 0);
+  assert(*ptrs[0] + *ptrs[1], 30);
+  assert(n, 0);
+  assert(m, 3);
+  return 0;
+}
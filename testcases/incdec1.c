@@ -0,0 +1,59 @@
+int g = 5;
+
+int main() {
+  int i = 0;
+  assert(i++, 0);
+  assert(i, 1);
+  assert(++i, 2);
+  assert(i--, 2);
+  assert(--i, 0);
+
+  int sum = 0;
+  for (i = 0; i < 5; i++) {
+    sum += i;
+  }
+  assert(sum, 10);
+
+  int x = 10;
+  assert(x += 5, 15);
+  assert(x -= 3, 12);
+  assert(x *= 2, 24);
+  assert(x /= 5, 4);
+  assert(x %= 3, 1);
+  x = 12;
+  assert(x &= 10, 8);
+  assert(x |= 3, 11);
+  assert(x ^= 1, 10);
+  assert(x <<= 2, 40);
+  assert(x >>= 3, 5);
+
+  int a[4] = {1, 2, 3, 4};
+  int *p = a;
+  p++;
+  assert(*p, 2);
+  assert(*p++, 2);
+  assert(*p, 3);
+  p += 1;
+  assert(*p, 4);
+  p -= 3;
+  assert(*p, 1);
+  assert(*++p, 2);
+  --p;
+  assert(*p, 1);
+
+  int j = 0;
+  a[j++] += 10;
+  assert(j, 1);
+  assert(a[0], 11);
+  a[1]++;
+  assert(a[1], 3);
+
+  char c = 127;
+  c++;
+  assertC(c, -128);
+
+  g++;
+  g += 2;
+  assert(g, 8);
+  return 0;
+}
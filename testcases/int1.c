@@ -0,0 +1,91 @@
+short gshort = -2;
+unsigned short gushort = 65535;
+long glong = 4294967296;
+unsigned guint = 4294967295;
+
+short half(short x) {
+  return x / 2;
+}
+
+unsigned char low(int x) {
+  return x;
+}
+
+long wide(long x) {
+  return x * 2;
+}
+
+int main() {
+  short s1;
+  short int s2;
+  long l1;
+  long long l2;
+  long unsigned int l3;
+  unsigned u1;
+  signed char c1;
+  unsigned char c2;
+  assert(sizeof(s1), 2);
+  assert(sizeof(s2), 2);
+  assert(sizeof(l1), 8);
+  assert(sizeof(l2), 8);
+  assert(sizeof(l3), 8);
+  assert(sizeof(u1), 4);
+  assert(sizeof(c1), 1);
+  assert(sizeof(c2), 1);
+
+  char c = 127;
+  c++;
+  assert(c, -128);
+  unsigned char uc = 255;
+  uc++;
+  assert(uc, 0);
+  uc = -1;
+  assert(uc, 255);
+  assert(uc > 0, 1);
+
+  short s = 32767;
+  s = s + 1;
+  assert(s, -32768);
+  unsigned short us = -1;
+  assert(us, 65535);
+  assert(half(-9), -4);
+  assert(low(257), 1);
+
+  assert(-1 < 0, 1);
+  assert(-3 < -2, 1);
+  assert(-2 >= -2, 1);
+  assert(-1 > 1, 0);
+  assert(-1 < 1u, 0);
+  assert(1u > -1, 0);
+  assert(-7 / 2, -3);
+  assert(-7 % 2, -1);
+  assert(-8 >> 1, -4);
+
+  unsigned u = -1;
+  assert(u / 2 == 2147483647, 1);
+  assert(u % 10, 5);
+  assert(u >> 31, 1);
+  assert(u == 4294967295, 1);
+  assert(u + 1, 0);
+
+  long l = 2147483647;
+  l = l + 1;
+  assert(l > 0, 1);
+  assert(l == 2147483648, 1);
+  assert(wide(l) == 4294967296, 1);
+  long long ll = -1;
+  assert(ll < 0, 1);
+  unsigned long ul = -1;
+  assert(ul > 0, 1);
+  assert(ul >> 63, 1);
+
+  int i = 2147483647;
+  i = i + 1;
+  assert(i < 0, 1);
+
+  assert(gshort, -2);
+  assert(gushort, 65535);
+  assert(glong == 4294967296, 1);
+  assert(guint / 2 == 2147483647, 1);
+  return 0;
+}
@@ -0,0 +1,59 @@
+int main() {
+  int i;
+  int sum = 0;
+  for (i = 0; i < 10; i++) {
+    if (i == 5)
+      break;
+    if (i % 2 == 0)
+      continue;
+    sum += i;
+  }
+  assert(i, 5);
+  assert(sum, 4);
+
+  i = 0;
+  sum = 0;
+  while (1) {
+    i++;
+    if (i > 6)
+      break;
+    if (i == 3)
+      continue;
+    sum += i;
+  }
+  assert(sum, 18);
+
+  i = 0;
+  do {
+    i++;
+    if (i < 3)
+      continue;
+    break;
+  } while (1);
+  assert(i, 3);
+
+  int j;
+  int n = 0;
+  for (i = 0; i < 3; i++) {
+    for (j = 0; j < 3; j++) {
+      if (j == 1)
+        break;
+      n++;
+    }
+  }
+  assert(n, 3);
+
+  i = 0;
+loop:
+  i++;
+  if (i < 4)
+    goto loop;
+  assert(i, 4);
+
+  goto skip;
+  i = 100;
+skip:
+  assert(i, 4);
+
+  return 0;
+}
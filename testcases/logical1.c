@@ -0,0 +1,35 @@
+int cnt;
+int *p;
+
+int hit(int x) {
+  cnt = cnt + 1;
+  return x;
+}
+
+int main() {
+  assert(1 && 2, 1);
+  assert(1 && 0, 0);
+  assert(0 || 0, 0);
+  assert(0 || 5, 1);
+  assert(!0, 1);
+  assert(!7, 0);
+  assert(!!7, 1);
+  assert(1 < 2 && 2 < 3, 1);
+  assert(1 > 2 || 3 == 3 && 0, 0);
+
+  cnt = 0;
+  assert(0 && hit(1), 0);
+  assert(cnt, 0);
+  assert(1 || hit(1), 1);
+  assert(cnt, 0);
+  assert(1 && hit(0), 0);
+  assert(cnt, 1);
+  assert(0 || hit(3), 1);
+  assert(cnt, 2);
+
+  assert(!p, 1);
+  int x = 3;
+  p = &x;
+  assert(p && *p == 3, 1);
+  return 0;
+}
@@ -0,0 +1,27 @@
+int h = 0x1F;
+int o = 017;
+
+int main() {
+  assert(h, 31);
+  assert(o, 15);
+  assert(0xff, 255);
+  assert(0XAb, 171);
+  assert(0755, 493);
+  assert(0b1010, 10);
+  assert(0B11, 3);
+  assert(10u, 10);
+  assert(10UL + 1, 11);
+  assert(sizeof(1), 4);
+  assert(sizeof(1u), 4);
+  assert(sizeof(1L), 8);
+  assert(sizeof(1ll), 8);
+  assert(sizeof(2147483647), 4);
+  assert(sizeof(2147483648), 8);
+  assert(sizeof(0xffffffff), 4);
+  int x;
+  int y = 7;
+  x = 4294967297;
+  assert(x, 1);
+  assert(y, 7);
+  return 0;
+}
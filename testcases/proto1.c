@@ -0,0 +1,50 @@
+int assert(int got, int want);
+int assertC(char, char);
+void printNum(int);
+
+int isEven(int n);
+int isOdd(int);
+long big(void);
+unsigned char low(int);
+static int twice(int x);
+char *pick(char *, int);
+
+int isEven(int n) {
+  if (n == 0)
+    return 1;
+  return isOdd(n - 1);
+}
+
+int isOdd(int n) {
+  if (n == 0)
+    return 0;
+  return isEven(n - 1);
+}
+
+int main() {
+  assert(isEven(10), 1);
+  assert(isOdd(7), 1);
+  assert(big() == 5000000000, 1);
+  assert(big() / 1000000000, 5);
+  assert(low(258), 2);
+  assert(twice(21), 42);
+  assertC(*pick("abc", 2), 'c');
+  printNum(7);
+  return 0;
+}
+
+long big() {
+  return 5000000000;
+}
+
+unsigned char low(int x) {
+  return x;
+}
+
+int twice(int x) {
+  return x * 2;
+}
+
+char *pick(char *s, int i) {
+  return s + i;
+}
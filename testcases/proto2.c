@@ -0,0 +1,32 @@
+int assert(int got, int want);
+int assertS(char *got, char *want, int len);
+int sprintf(char *buf, char *fmt, ...);
+int printf(char *fmt, ...);
+
+int sum3();
+int sum3();
+long scale();
+long scale(long x, int k);
+
+int main() {
+  char buf[32];
+  assert(sprintf(buf, "%d-%s", 42, "ok"), 5);
+  assertS(buf, "42-ok", 6);
+  char c = 'x';
+  sprintf(buf, "%c%c%ld", c, 'y', 5000000000);
+  assertS(buf, "xy5000000000", 13);
+  printf("proto2 OK\n");
+
+  assert(sum3(1, 2, 3), 6);
+  assert(scale(-1, 3) < 0, 1);
+  assert(scale(2, 3) == 6, 1);
+  return 0;
+}
+
+int sum3(int a, int b, int c) {
+  return a + b + c;
+}
+
+long scale(long x, int k) {
+  return x * k;
+}
@@ -0,0 +1,47 @@
+int x = 7;
+
+int shadow(int a) {
+  int r = x;
+  {
+    int x = a;
+    r = r * 10 + x;
+    {
+      int x = a + 1;
+      r = r * 10 + x;
+    }
+    r = r * 10 + x;
+  }
+  return r * 10 + x;
+}
+
+int main() {
+  int sum = 0;
+  for (int i = 0; i < 3; i++)
+    sum += i;
+  for (int i = 10; i < 12; i++)
+    sum += i;
+  assert(sum, 24);
+
+  assert(shadow(2), 72327);
+
+  int a = 1;
+  {
+    int b = 2;
+    assert(a + b, 3);
+  }
+  {
+    int c = 5;
+    assert(c, 5);
+  }
+  {
+    int a = 9;
+    assert(a, 9);
+  }
+  assert(a, 1);
+
+  for (int j = 0; j < 2; j++) {
+    int j = 5;
+    assert(j, 5);
+  }
+  return 0;
+}
@@ -0,0 +1,37 @@
+struct P {
+  char a;
+  int b;
+};
+
+union U {
+  char c;
+  long l;
+};
+
+int main() {
+  assert(sizeof(int), 4);
+  assert(sizeof(char), 1);
+  assert(sizeof(short), 2);
+  assert(sizeof(long), 8);
+  assert(sizeof(unsigned char), 1);
+  assert(sizeof(long long int), 8);
+  assert(sizeof(int *), 8);
+  assert(sizeof(char **), 8);
+  assert(sizeof(int[3]), 12);
+  assert(sizeof(char *[4]), 32);
+  assert(sizeof(struct P), 8);
+  assert(sizeof(struct P *), 8);
+  assert(sizeof(struct P[3]), 24);
+  assert(sizeof(union U), 8);
+  assert(sizeof(struct {char a; int b; long c; char d;}), 24);
+  assert(sizeof(enum { A, B }), 4);
+  assert(sizeof(int) * 2 + 1, 9);
+  assert(sizeof (int), 4);
+
+  int x;
+  assert(sizeof(x), 4);
+  assert(sizeof(x + 1), 4);
+  int arr[sizeof(struct P)];
+  assert(sizeof(arr), 32);
+  return 0;
+}
@@ -0,0 +1,41 @@
+unsigned char gunsigned[] = "abc";
+signed char gsigned[6] = "xy";
+
+// dirty leaves non-zero values in the stack used by the next call.
+int dirty() {
+  long junk[2];
+  junk[0] = -1;
+  junk[1] = -1;
+  return 0;
+}
+
+int tail() {
+  char s[8] = "hi";
+  return s[2] + s[7];
+}
+
+int main() {
+  char a[6] = "hello";
+  assertS(a, "hello", 6);
+  char b[] = "abc";
+  assert(sizeof(b), 4);
+  assertS(b, "abc", 4);
+  char c[3] = "xyz";
+  assertC(c[2], 'z');
+
+  unsigned char u[] = "\377bc";
+  assert(sizeof(u), 4);
+  assert(u[0], 255);
+  assert(u[3], 0);
+  signed char s[4] = "ab";
+  assertC(s[1], 'b');
+  assert(s[3], 0);
+
+  assert(sizeof(gunsigned), 4);
+  assert(gunsigned[2], 'c');
+  assert(gsigned[1] + gsigned[5], 'y');
+
+  dirty();
+  assert(tail(), 0);
+  return 0;
+}
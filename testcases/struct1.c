@@ -0,0 +1,125 @@
+struct Point {
+  int x;
+  int y;
+};
+
+struct Node {
+  int val;
+  struct Node *next;
+};
+
+struct Mixed {
+  char c;
+  long l;
+  short s;
+};
+
+struct Point origin = {3, 4};
+struct Mixed gm = {'a', 100, 7};
+
+int sum(struct Point *p) {
+  return p->x + p->y;
+}
+
+int length(struct Node *n) {
+  int len = 0;
+  while (n) {
+    len++;
+    n = n->next;
+  }
+  return len;
+}
+
+// dirty leaves non-zero values in the stack used by the next call.
+int dirty() {
+  long junk[8];
+  for (int i = 0; i < 8; i++)
+    junk[i] = -1;
+  return junk[7];
+}
+
+int partialY() {
+  struct Mixed m = {'a'};
+  struct Point p = {1};
+  return p.y + m.l + m.s;
+}
+
+int main() {
+  struct Point p;
+  p.x = 1;
+  p.y = 2;
+  assert(p.x, 1);
+  assert(p.y, 2);
+  assert(sum(&p), 3);
+  assert(sizeof(p), 8);
+
+  struct Point *pp = &p;
+  pp->x = 10;
+  assert(p.x, 10);
+  assert((*pp).y, 2);
+
+  struct Point q = p;
+  q.y = 20;
+  assert(q.x, 10);
+  assert(p.y, 2);
+  p = q;
+  assert(p.y, 20);
+
+  struct Point r = {5, 6};
+  assert(r.x + r.y, 11);
+  assert(origin.x, 3);
+  assert(origin.y, 4);
+
+  struct Mixed m;
+  assert(sizeof(m), 24);
+  m.c = 'z';
+  m.l = 4294967296;
+  m.s = -1;
+  assertC(m.c, 'z');
+  assert(m.l == 4294967296, 1);
+  assert(m.s, -1);
+  assertC(gm.c, 'a');
+  assert(gm.l, 100);
+  assert(gm.s, 7);
+
+  struct {
+    char a;
+    int b;
+    char c;
+  } anon;
+  assert(sizeof(anon), 12);
+
+  struct Outer {
+    char tag;
+    struct Point pt;
+    int arr[3];
+  } o;
+  assert(sizeof(o), 24);
+  o.pt.x = 7;
+  o.pt.y = 8;
+  o.arr[2] = 9;
+  assert(o.pt.x * o.pt.y, 56);
+  assert(o.arr[2], 9);
+
+  struct Point pts[3];
+  for (int i = 0; i < 3; i++) {
+    pts[i].x = i;
+    pts[i].y = i * 10;
+  }
+  assert(pts[2].x + pts[1].y, 12);
+  assert(sizeof(pts), 24);
+
+  struct Node n3 = {3, 0};
+  struct Node n2 = {2, &n3};
+  struct Node n1 = {1, &n2};
+  assert(length(&n1), 3);
+  assert(n1.next->next->val, 3);
+
+  int *ip = &o.arr[0];
+  ip[1] = 42;
+  assert(o.arr[1], 42);
+
+  dirty();
+  assert(partialY(), 0);
+  return 0;
+}
@@ -0,0 +1,87 @@
+int small(int x) {
+  int r = 0;
+  switch (x) {
+  case 1:
+    r = 10;
+    break;
+  case 5:
+    r = 50;
+  case 6:
+    r += 1;
+    break;
+  default:
+    r = -1;
+  }
+  return r;
+}
+
+int dense(int x) {
+  switch (x) {
+  case -1:
+    return 100;
+  case 0:
+    return 200;
+  case 1:
+  case 2:
+    return 300;
+  case 4:
+    return 500;
+  case 5:
+    return 600;
+  }
+  return 0;
+}
+
+int dense_default(char c) {
+  int n = 0;
+  switch (c) {
+  case 'a': n = 1; break;
+  case 'b': n = 2; break;
+  case 'c': n = 3; break;
+  case 'd': n = 4; break;
+  case 'f': n = 6; break;
+  default: n = 9;
+  }
+  return n;
+}
+
+int main() {
+  assert(small(1), 10);
+  assert(small(5), 51);
+  assert(small(6), 1);
+  assert(small(7), -1);
+
+  assert(dense(-1), 100);
+  assert(dense(0), 200);
+  assert(dense(1), 300);
+  assert(dense(2), 300);
+  assert(dense(3), 0);
+  assert(dense(4), 500);
+  assert(dense(5), 600);
+  assert(dense(6), 0);
+  assert(dense(-2), 0);
+  assert(dense(-2147483647 - 1), 0);
+
+  assert(dense_default('a'), 1);
+  assert(dense_default('d'), 4);
+  assert(dense_default('e'), 9);
+  assert(dense_default('f'), 6);
+  assert(dense_default('z'), 9);
+
+  int i;
+  int sum = 0;
+  for (i = 0; i < 5; i++) {
+    switch (i) {
+    case 2:
+      continue;
+    case 3:
+      break;
+    default:
+      sum += i;
+    }
+    sum += 10;
+  }
+  assert(sum, 45);
+
+  return 0;
+}
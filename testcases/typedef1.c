@@ -0,0 +1,91 @@
+typedef int *IntPtr;
+typedef int Int, Arr3[3];
+typedef struct {
+  int x;
+  int y;
+} Point;
+typedef struct Node Node;
+struct Node {
+  int val;
+  Node *next;
+};
+typedef enum { OFF, ON } Switch;
+typedef int Int;
+typedef int Flex[];
+
+Int gi = 7;
+Arr3 garr = {1, 2, 3};
+
+Int plus(Int a, Int b) {
+  return a + b;
+}
+
+int sum(Point *p) {
+  return p->x + p->y;
+}
+
+int main() {
+  Int i = 3;
+  IntPtr p = &i;
+  *p = 4;
+  assert(i, 4);
+  assert(sizeof(p), 8);
+  assert(plus(gi, 1), 8);
+  assert(garr[2], 3);
+
+  Arr3 a;
+  assert(sizeof(a), 12);
+  Flex f1 = {1, 2};
+  Flex f2 = {1, 2, 3, 4};
+  assert(sizeof(f1), 8);
+  assert(sizeof(f2), 16);
+
+  Point pt = {1, 2};
+  assert(sum(&pt), 3);
+
+  Node n2 = {2, 0};
+  Node n1 = {1, &n2};
+  assert(n1.next->val, 2);
+
+  Switch s = ON;
+  assert(s, 1);
+
+  // T * x declares x while T is a typedef name, and multiplies after int
+  // T hides it.
+  typedef int T;
+  int x = 6;
+  {
+    T *x = &i;
+    assert(*x, 4);
+  }
+  {
+    int T = 2;
+    assert(T * x, 12);
+  }
+  T y = 5;
+  assert(y, 5);
+
+  {
+    typedef char T;
+    T c;
+    assert(sizeof(c), 1);
+  }
+  T z;
+  assert(sizeof(z), 4);
+  assert(sizeof(T), 4);
+  assert(sizeof(Point), 8);
+  assert(sizeof(Arr3), 12);
+  assert(sizeof(IntPtr *), 8);
+  {
+    char T;
+    assert(sizeof(T), 1);
+  }
+
+  for (Int k = 0; k < 3; k++)
+    i++;
+  assert(i, 7);
+
+  goto T;
+T:
+  return 0;
+}
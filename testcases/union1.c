@@ -0,0 +1,85 @@
+union Value {
+  char c;
+  int i;
+  long l;
+};
+
+struct Variant {
+  int kind;
+  union Value v;
+};
+
+union Value gv = {65};
+
+union Bits {
+  int i;
+  char bytes[4];
+};
+
+int get(struct Variant *var) {
+  switch (var->kind) {
+  case 0:
+    return var->v.c;
+  case 1:
+    return var->v.i;
+  }
+  return var->v.l / 1000;
+}
+
+// dirty leaves non-zero values in the stack used by the next call.
+int dirty() {
+  long junk[2];
+  junk[0] = -1;
+  junk[1] = -1;
+  return 0;
+}
+
+long partialWide() {
+  union Value v = {1};
+  return v.l;
+}
+
+int main() {
+  union Value u;
+  assert(sizeof(u), 8);
+  u.l = 0;
+  u.i = 258;
+  assert(u.i, 258);
+  assert(u.c, 2);
+  assert(&u.c == &u.i, 1);
+
+  union Bits b;
+  b.i = 0x04030201;
+  assert(b.bytes[0], 1);
+  assert(b.bytes[3], 4);
+  b.bytes[1] = 0;
+  assert(b.i, 0x04030001);
+
+  union {
+    char c[5];
+    int i;
+  } odd;
+  assert(sizeof(odd), 8);
+
+  struct Variant vars[3];
+  vars[0].kind = 0;
+  vars[0].v.c = 'x';
+  vars[1].kind = 1;
+  vars[1].v.i = 42;
+  vars[2].kind = 2;
+  vars[2].v.l = 5000000000;
+  assert(sizeof(vars[0]), 16);
+  assert(get(&vars[0]), 'x');
+  assert(get(&vars[1]), 42);
+  assert(get(&vars[2]), 5000000);
+
+  union Value w = {7};
+  assert(w.c, 7);
+  union Value copy = w;
+  assert(copy.c, 7);
+  assert(gv.c, 'A');
+
+  dirty();
+  assert(partialWide(), 1);
+  return 0;
+}
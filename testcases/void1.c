@@ -0,0 +1,42 @@
+int g;
+char s[4] = "abc";
+
+void set(int v) {
+  g = v;
+}
+
+void setIfNonZero(int v) {
+  if (v == 0)
+    return;
+  g = v;
+}
+
+void nothing(void) {
+}
+
+void *pass(void *p) {
+  return p;
+}
+
+int main() {
+  set(3);
+  assert(g, 3);
+  setIfNonZero(0);
+  assert(g, 3);
+  setIfNonZero(5);
+  assert(g, 5);
+  nothing();
+
+  int x = 42;
+  void *p = &x;
+  int *q = p;
+  assert(*q, 42);
+
+  void *v = s;
+  char *c = pass(v);
+  assertC(*(c + 1), 'b');
+
+  g ? set(1) : set(2);
+  assert(g, 1);
+  return 0;
+}
@@ -2,9 +2,9 @@ package token
 
 import (
 	"bytes"
-	"fmt"
+	"go9cc/diag"
 	"go9cc/emoji"
-	"os"
+	"math"
 	"strings"
 	"unicode"
 )
@@ -28,6 +28,32 @@ const (
 	GT         = ">"
 	GTE        = ">="
 	AND        = "&"
+	PERCENT    = "%"
+	INC        = "++"
+	DEC        = "--"
+	ADD_ASSIGN = "+="
+	SUB_ASSIGN = "-="
+	MUL_ASSIGN = "*="
+	DIV_ASSIGN = "/="
+	MOD_ASSIGN = "%="
+	AND_ASSIGN = "&="
+	OR_ASSIGN  = "|="
+	XOR_ASSIGN = "^="
+	SHL_ASSIGN = "<<="
+	SHR_ASSIGN = ">>="
+	LAND       = "&&"
+	LOR        = "||"
+	NOT        = "!"
+	TILDE      = "~"
+	OR         = "|"
+	XOR        = "^"
+	SHL        = "<<"
+	SHR        = ">>"
+	QUESTION   = "?"
+	COLON      = ":"
+	ARROW      = "->"
+	DOT        = "."
+	ELLIPSIS   = "..."
 	NUM        = "NUM"
 	STRING     = "STRING"
 	IDENT      = "IDENT"
@@ -36,11 +62,22 @@ const (
 	COMMA      = ","
 	RETURN     = "RETURN"
 	SIZEOF     = "SIZEOF"
+	STATIC     = "STATIC"
 	IF         = "IF"
 	ELSE       = "ELSE"
 	WHILE      = "WHILE"
 	FOR        = "FOR"
 	DO         = "DO"
+	BREAK      = "BREAK"
+	CONTINUE   = "CONTINUE"
+	GOTO       = "GOTO"
+	SWITCH     = "SWITCH"
+	CASE       = "CASE"
+	DEFAULT    = "DEFAULT"
+	STRUCT     = "STRUCT"
+	UNION      = "UNION"
+	ENUM       = "ENUM"
+	TYPEDEF    = "TYPEDEF"
 	EOF        = "EOF"
 	START      = "START"
 )
@@ -54,10 +91,15 @@ type Token struct {
 	Val  int
 	Str  string
 	Col  int
+	File *diag.File
+}
+
+func (t *Token) Pos() diag.Pos {
+	return diag.Pos{File: t.File, Offset: t.Col}
 }
 
 func newToken(kind TokenKind, curToken *Token, val int, str string, col int) *Token {
-	token := Token{Kind: kind, Val: val, Str: str, Col: col}
+	token := Token{Kind: kind, Val: val, Str: str, Col: col, File: curToken.File}
 	token.Prev = curToken
 	curToken.Next = &token
 	return &token
@@ -66,33 +108,31 @@ func newToken(kind TokenKind, curToken *Token, val int, str string, col int) *To
 type Tokenizer struct {
 	col  int
 	code []rune
+	file *diag.File
 }
 
 func New(code string) *Tokenizer {
-	return &Tokenizer{col: 0, code: []rune(code)}
+	return NewFile(diag.NewFile("", code))
 }
 
-func (t *Tokenizer) Error(token *Token, msg string, args ...interface{}) {
-	line, row, col := getLine(t.code, token.Col)
-	prefix := fmt.Sprintf("line %d: ", row+1)
-	fmt.Fprintf(os.Stderr, prefix)
-	fmt.Fprintln(os.Stderr, line)
-	fmt.Fprintf(os.Stderr, strings.Repeat(" ", len(prefix)+col))
-	fmt.Fprintf(os.Stderr, "^ "+msg+"\n", args...)
-	os.Exit(1)
+func NewFile(file *diag.File) *Tokenizer {
+	return &Tokenizer{col: 0, code: file.Code, file: file}
 }
 
-func (t *Tokenizer) errorCurrent(msg string, args ...interface{}) {
-	fmt.Println(t.col)
-	fmt.Fprintln(os.Stderr, string(t.code))
-	for i := 0; i < t.col; i++ {
-		fmt.Printf(" ")
+func (t *Tokenizer) Error(token *Token, msg string, args ...interface{}) *diag.Diagnostic {
+	var pos diag.Pos
+	if token != nil {
+		pos = token.Pos()
 	}
-	fmt.Fprintf(os.Stderr, "^ "+msg+"\n", args...)
-	os.Exit(1)
+	return diag.Errorf(pos, diag.Syntax, msg, args...)
 }
 
-func (t *Tokenizer) Expect(token *Token, kinds ...TokenKind) {
+func (t *Tokenizer) errorCurrent(msg string, args ...interface{}) *diag.Diagnostic {
+	pos := diag.Pos{File: t.file, Offset: t.col}
+	return diag.Errorf(pos, diag.Lex, msg, args...)
+}
+
+func (t *Tokenizer) Expect(token *Token, kinds ...TokenKind) error {
 	match := false
 	ss := []string{}
 	for _, kind := range kinds {
@@ -101,9 +141,10 @@ func (t *Tokenizer) Expect(token *Token, kinds ...TokenKind) {
 	}
 
 	if !match {
-		t.Error(token, "Expected %s. Got %s.", strings.Join(ss, " or "), token.Kind)
-		os.Exit(1)
+		return t.Error(token, "Expected %s. Got %s.", strings.Join(ss, " or "), token.Kind)
 	}
+
+	return nil
 }
 
 func (t *Tokenizer) curCh() rune {
@@ -114,23 +155,14 @@ func (t *Tokenizer) curCh() rune {
 	return t.code[t.col]
 }
 
-func (t *Tokenizer) Tokenize() *Token {
+func (t *Tokenizer) Tokenize() (*Token, error) {
 	t.col = skip(t.code, 0)
 
-	head := &Token{START, nil, nil, 0, "", 0}
+	head := &Token{Kind: START, File: t.file}
 	cur := head
 
 	for {
 		switch t.curCh() {
-		case '+':
-			cur = newToken(PLUS, cur, 0, string(t.curCh()), t.col)
-			t.col++
-		case '-':
-			cur = newToken(MINUS, cur, 0, string(t.curCh()), t.col)
-			t.col++
-		case '*':
-			cur = newToken(ASTERISK, cur, 0, string(t.curCh()), t.col)
-			t.col++
 		case '/':
 			t.col++
 			if t.curCh() == '/' {
@@ -140,92 +172,56 @@ func (t *Tokenizer) Tokenize() *Token {
 				t.col = skipUntil(t.code, t.col+1, []rune("*/"))
 			} else {
 				t.col--
-				cur = newToken(SLASH, cur, 0, string(t.curCh()), t.col)
-				t.col++
+				kind := readPunct(t.code, t.col)
+				cur = newToken(kind, cur, 0, string(kind), t.col)
+				t.col += len(kind)
 			}
-		case '(':
-			cur = newToken(LPAREN, cur, 0, string(t.curCh()), t.col)
-			t.col++
-		case ')':
-			cur = newToken(RPAREN, cur, 0, string(t.curCh()), t.col)
-			t.col++
-		case '{':
-			cur = newToken(LBRACE, cur, 0, string(t.curCh()), t.col)
-			t.col++
-		case '}':
-			cur = newToken(RBRACE, cur, 0, string(t.curCh()), t.col)
-			t.col++
-		case '[':
-			cur = newToken(LBRACKET, cur, 0, string(t.curCh()), t.col)
-			t.col++
-		case ']':
-			cur = newToken(RBRACKET, cur, 0, string(t.curCh()), t.col)
-			t.col++
-		case ',':
-			cur = newToken(COMMA, cur, 0, string(t.curCh()), t.col)
-			t.col++
-		case '<':
-			t.col++
-			if t.curCh() == '=' {
-				t.col--
-				cur = newToken(LTE, cur, 0, "<=", t.col)
-				t.col += 2
-			} else {
-				t.col--
-				cur = newToken(LT, cur, 0, string(t.curCh()), t.col)
-				t.col++
-			}
-		case '>':
-			t.col++
-			if t.curCh() == '=' {
-				t.col--
-				cur = newToken(GTE, cur, 0, ">=", t.col)
-				t.col += 2
-			} else {
-				t.col--
-				cur = newToken(GT, cur, 0, string(t.curCh()), t.col)
-				t.col++
-			}
-		case '=':
-			t.col++
-			if t.curCh() == '=' {
-				t.col--
-				cur = newToken(EQ, cur, 0, "==", t.col)
-				t.col += 2
-			} else {
-				t.col--
-				cur = newToken(ASSIGN, cur, 0, "=", t.col)
-				t.col++
+		case '"':
+			start := t.col
+			str, err := t.readString()
+			if err != nil {
+				return nil, err
 			}
-		case '!':
-			t.col++
-			if t.curCh() != '=' {
-				t.errorCurrent("Unexpected char: %s", string(t.curCh()))
+			cur = newToken(STRING, cur, 0, str, start)
+		case '\'':
+			// 文字リテラルはint型の数値
+			start := t.col
+			val, err := t.readChar()
+			if err != nil {
+				return nil, err
 			}
-			t.col--
-			cur = newToken(NEQ, cur, 0, "!=", t.col)
-			t.col += 2
-		case ';':
-			cur = newToken(SEMICOLLON, cur, 0, ";", t.col)
-			t.col++
-		case '"':
-			t.col++
-			str, newIdx := readString(t.code, t.col)
-			cur = newToken(STRING, cur, 0, str, t.col-1)
-			t.col = newIdx
-		case '&':
-			cur = newToken(AND, cur, 0, "&", t.col)
-			t.col++
+			cur = newToken(NUM, cur, val, string(t.code[start:t.col]), start)
 		case 0:
 			cur = newToken(EOF, cur, 0, "", t.col)
-			return head.Next
+			return head.Next, nil
 		default:
-			if newcol, ok := tryKeyword(t.code, t.col, "char"); ok {
+			if kind := readPunct(t.code, t.col); kind != "" {
+				cur = newToken(kind, cur, 0, string(kind), t.col)
+				t.col += len(kind)
+			} else if newcol, ok := tryKeyword(t.code, t.col, "void"); ok {
+				cur = newToken(TYPE, cur, 0, "void", t.col)
+				t.col = newcol
+			} else if newcol, ok := tryKeyword(t.code, t.col, "char"); ok {
 				cur = newToken(TYPE, cur, 0, "char", t.col)
 				t.col = newcol
+			} else if newcol, ok := tryKeyword(t.code, t.col, "short"); ok {
+				cur = newToken(TYPE, cur, 0, "short", t.col)
+				t.col = newcol
+			} else if newcol, ok := tryKeyword(t.code, t.col, "long"); ok {
+				cur = newToken(TYPE, cur, 0, "long", t.col)
+				t.col = newcol
+			} else if newcol, ok := tryKeyword(t.code, t.col, "unsigned"); ok {
+				cur = newToken(TYPE, cur, 0, "unsigned", t.col)
+				t.col = newcol
+			} else if newcol, ok := tryKeyword(t.code, t.col, "signed"); ok {
+				cur = newToken(TYPE, cur, 0, "signed", t.col)
+				t.col = newcol
 			} else if newcol, ok := tryKeyword(t.code, t.col, "sizeof"); ok {
 				cur = newToken(SIZEOF, cur, 0, "sizeof", t.col)
 				t.col = newcol
+			} else if newcol, ok := tryKeyword(t.code, t.col, "static"); ok {
+				cur = newToken(STATIC, cur, 0, "static", t.col)
+				t.col = newcol
 			} else if newcol, ok := tryKeyword(t.code, t.col, "int"); ok {
 				cur = newToken(TYPE, cur, 0, "int", t.col)
 				t.col = newcol
@@ -247,18 +243,50 @@ func (t *Tokenizer) Tokenize() *Token {
 			} else if newcol, ok := tryKeyword(t.code, t.col, "do"); ok {
 				cur = newToken(DO, cur, 0, "do", t.col)
 				t.col = newcol
-			} else if isDigit(t.curCh()) {
-				intVal, newcol := readInteger(t.code, t.col)
-				cur = newToken(NUM, cur, intVal, fmt.Sprintf("%d", intVal), t.col)
+			} else if newcol, ok := tryKeyword(t.code, t.col, "break"); ok {
+				cur = newToken(BREAK, cur, 0, "break", t.col)
+				t.col = newcol
+			} else if newcol, ok := tryKeyword(t.code, t.col, "continue"); ok {
+				cur = newToken(CONTINUE, cur, 0, "continue", t.col)
+				t.col = newcol
+			} else if newcol, ok := tryKeyword(t.code, t.col, "goto"); ok {
+				cur = newToken(GOTO, cur, 0, "goto", t.col)
+				t.col = newcol
+			} else if newcol, ok := tryKeyword(t.code, t.col, "switch"); ok {
+				cur = newToken(SWITCH, cur, 0, "switch", t.col)
+				t.col = newcol
+			} else if newcol, ok := tryKeyword(t.code, t.col, "case"); ok {
+				cur = newToken(CASE, cur, 0, "case", t.col)
+				t.col = newcol
+			} else if newcol, ok := tryKeyword(t.code, t.col, "default"); ok {
+				cur = newToken(DEFAULT, cur, 0, "default", t.col)
 				t.col = newcol
+			} else if newcol, ok := tryKeyword(t.code, t.col, "struct"); ok {
+				cur = newToken(STRUCT, cur, 0, "struct", t.col)
+				t.col = newcol
+			} else if newcol, ok := tryKeyword(t.code, t.col, "union"); ok {
+				cur = newToken(UNION, cur, 0, "union", t.col)
+				t.col = newcol
+			} else if newcol, ok := tryKeyword(t.code, t.col, "enum"); ok {
+				cur = newToken(ENUM, cur, 0, "enum", t.col)
+				t.col = newcol
+			} else if newcol, ok := tryKeyword(t.code, t.col, "typedef"); ok {
+				cur = newToken(TYPEDEF, cur, 0, "typedef", t.col)
+				t.col = newcol
+			} else if isDigit(t.curCh()) {
+				start := t.col
+				intVal, err := t.readInteger()
+				if err != nil {
+					return nil, err
+				}
+				cur = newToken(NUM, cur, intVal, string(t.code[start:t.col]), start)
 			} else if isIdent(t.curCh()) {
 				strVal, newcol := readIdent(t.code, t.col)
 				cur = newToken(IDENT, cur, 0, strVal, t.col)
 				t.col = newcol
 			} else {
 				t.col = skip(t.code, t.col)
-				t.errorCurrent("Unexpected char: %s", string(t.curCh()))
-				os.Exit(1)
+				return nil, t.errorCurrent("Unexpected char: %s", string(t.curCh()))
 			}
 		}
 
@@ -266,6 +294,54 @@ func (t *Tokenizer) Tokenize() *Token {
 	}
 }
 
+// StripComments replaces each comment of code by a space. gocc has no
+// preprocessor, so this is all that -E does. Newlines of block comments
+// are kept in order not to move the following lines.
+func StripComments(code string) string {
+	s := []rune(code)
+	var out bytes.Buffer
+	for p := 0; p < len(s); p++ {
+		switch {
+		case s[p] == '"' || s[p] == '\'':
+			// Copy literals as is: they may contain "//" or "/*".
+			quote := s[p]
+			out.WriteRune(s[p])
+			for p++; p < len(s) && s[p] != quote && s[p] != '\n'; p++ {
+				if s[p] == '\\' && p+1 < len(s) {
+					out.WriteRune(s[p])
+					p++
+				}
+				out.WriteRune(s[p])
+			}
+			if p < len(s) {
+				out.WriteRune(s[p])
+			}
+		case s[p] == '/' && p+1 < len(s) && s[p+1] == '/':
+			p = skipUntil(s, p+2, []rune("\n")) - 1
+			out.WriteString(" ")
+			if p < len(s) && s[p] == '\n' {
+				out.WriteRune('\n')
+			}
+		case s[p] == '/' && p+1 < len(s) && s[p+1] == '*':
+			end := skipUntil(s, p+2, []rune("*/"))
+			if end > len(s) {
+				end = len(s)
+			}
+			out.WriteString(" ")
+			for _, ch := range s[p:end] {
+				if ch == '\n' {
+					out.WriteRune(ch)
+				}
+			}
+			p = end - 1
+		default:
+			out.WriteRune(s[p])
+		}
+	}
+
+	return out.String()
+}
+
 func isWS(ch rune) bool {
 	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
 }
@@ -341,37 +417,222 @@ func tryKeyword(s []rune, start int, keyword string) (int, bool) {
 	return start, false
 }
 
-func readInteger(s []rune, start int) (int, int) {
-	p := skip(s, start)
-	val := 0
-	for p < len(s) && isDigit(s[p]) {
-		val *= 10
-		val += int(s[p] - 48)
-		p++
+// punctuators are sorted by length for the longest match: "<<=" is
+// not read as "<" "<=".
+var punctuators = []TokenKind{
+	ELLIPSIS, SHL_ASSIGN, SHR_ASSIGN,
+	INC, DEC, ADD_ASSIGN, SUB_ASSIGN, MUL_ASSIGN, DIV_ASSIGN, MOD_ASSIGN,
+	AND_ASSIGN, OR_ASSIGN, XOR_ASSIGN, LAND, LOR, SHL, SHR, ARROW,
+	EQ, NEQ, LTE, GTE,
+	PLUS, MINUS, ASTERISK, SLASH, PERCENT, LPAREN, RPAREN, LBRACE, RBRACE,
+	LBRACKET, RBRACKET, COMMA, SEMICOLLON, LT, GT, ASSIGN, AND, OR, XOR,
+	NOT, TILDE, QUESTION, COLON, DOT,
+}
+
+// readPunct returns the longest punctuator at start, or "" if none.
+func readPunct(s []rune, start int) TokenKind {
+	for _, kind := range punctuators {
+		end := start + len(kind)
+		if end <= len(s) && string(s[start:end]) == string(kind) {
+			return kind
+		}
 	}
 
-	return val, p
+	return ""
 }
 
-func readString(s []rune, start int) (string, int) {
-	p := skip(s, start)
+// readInteger reads a decimal, octal (0755), hex (0x1F) or binary
+// (0b1010) literal with an optional u/l/ul/ll suffix. Values above
+// LONG_MAX wrap around to negative ints; the parser types them by Str.
+func (t *Tokenizer) readInteger() (int, error) {
+	start := t.col
+	base := 10
+	if t.curCh() == '0' && t.col+1 < len(t.code) {
+		switch t.code[t.col+1] {
+		case 'x', 'X':
+			base = 16
+			t.col += 2
+		case 'b', 'B':
+			base = 2
+			t.col += 2
+		default:
+			base = 8
+		}
+	}
+
+	digits := t.col
+	var val uint64
+	overflow := false
+	for isIdent(t.curCh()) || isDigit(t.curCh()) {
+		d := hexVal(t.curCh())
+		if d < 0 || d >= base {
+			break
+		}
+
+		if val > (math.MaxUint64-uint64(d))/uint64(base) {
+			overflow = true
+		}
+		val = val*uint64(base) + uint64(d)
+		t.col++
+	}
+
+	if t.col == digits && base != 8 {
+		t.col = start
+		return 0, t.errorCurrent("Invalid integer constant: no digits")
+	}
+
+	suffix, end := readIdent(t.code, t.col)
+	if !IsIntSuffix(suffix) {
+		if base == 8 && isDigit(t.curCh()) {
+			return 0, t.errorCurrent("Invalid digit %s in octal constant", string(t.curCh()))
+		}
+		return 0, t.errorCurrent("Invalid suffix %s on integer constant", suffix)
+	}
+	t.col = end
+
+	if overflow {
+		t.col = start
+		return 0, t.errorCurrent("Integer literal %s is too large to be represented in any integer type", string(t.code[start:end]))
+	}
+
+	return int(val), nil
+}
+
+// IsIntSuffix reports whether s is a valid integer suffix like "ul".
+func IsIntSuffix(s string) bool {
+	switch strings.ToLower(s) {
+	case "", "u", "l", "ul", "lu", "ll", "ull", "llu":
+		return true
+	}
+	return false
+}
+
+// readString reads a string literal at the opening quote and returns
+// its bytes with escape sequences decoded.
+func (t *Tokenizer) readString() (string, error) {
+	start := t.col
+	t.col++ // "
 	var out bytes.Buffer
-	for p < len(s) && s[p] != '"' {
-		out.WriteRune(s[p])
-		p++
+	for t.col < len(t.code) && t.code[t.col] != '"' {
+		if t.code[t.col] != '\\' {
+			out.WriteRune(t.code[t.col])
+			t.col++
+			continue
+		}
+
+		val, err := t.readEscape()
+		if err != nil {
+			return "", err
+		}
+		out.WriteByte(byte(val))
 	}
 
-	if p == len(s) {
-		line, row, _ := getLine(s, p)
-		prefix := fmt.Sprintf("line %d: ", row+1)
-		fmt.Fprintf(os.Stderr, prefix)
-		fmt.Fprintln(os.Stderr, line)
-		fmt.Fprintf(os.Stderr, "string not ended\n")
-		os.Exit(1)
+	if t.col == len(t.code) {
+		t.col = start
+		return "", t.errorCurrent("string not ended")
 	}
 
-	p++ // "
-	return out.String(), p
+	t.col++ // "
+	return out.String(), nil
+}
+
+// readChar reads a character literal at the opening quote. Its value is
+// a signed char like gcc: '\xff' is -1.
+func (t *Tokenizer) readChar() (int, error) {
+	start := t.col
+	t.col++ // '
+	var val int
+	switch t.curCh() {
+	case 0, '\n':
+		t.col = start
+		return 0, t.errorCurrent("char not ended")
+	case '\'':
+		t.col = start
+		return 0, t.errorCurrent("Empty character constant")
+	case '\\':
+		v, err := t.readEscape()
+		if err != nil {
+			return 0, err
+		}
+		val = int(int8(v))
+	default:
+		val = int(t.curCh())
+		t.col++
+	}
+
+	if t.curCh() != '\'' {
+		t.col = start
+		return 0, t.errorCurrent("char not ended")
+	}
+
+	t.col++ // '
+	return val, nil
+}
+
+var escapes = map[rune]int{
+	'a':  7,
+	'b':  8,
+	't':  9,
+	'n':  10,
+	'v':  11,
+	'f':  12,
+	'r':  13,
+	'e':  27, // GNU拡張
+	'\\': '\\',
+	'\'': '\'',
+	'"':  '"',
+	'?':  '?',
+}
+
+// readEscape reads an escape sequence at the backslash and returns the
+// byte it stands for: \n, octal \123 or hex \x41.
+func (t *Tokenizer) readEscape() (int, error) {
+	t.col++ // \
+	ch := t.curCh()
+	switch {
+	case '0' <= ch && ch <= '7':
+		val := 0
+		for i := 0; i < 3 && '0' <= t.curCh() && t.curCh() <= '7'; i++ {
+			val = val*8 + int(t.curCh()-'0')
+			t.col++
+		}
+		return val & 0xff, nil
+	case ch == 'x':
+		t.col++
+		if hexVal(t.curCh()) < 0 {
+			return 0, t.errorCurrent("\\x used with no following hex digits")
+		}
+
+		val := 0
+		for hexVal(t.curCh()) >= 0 {
+			val = val*16 + hexVal(t.curCh())
+			t.col++
+		}
+		return val & 0xff, nil
+	case ch == 0:
+		return 0, t.errorCurrent("string not ended")
+	}
+
+	t.col++
+	if val, ok := escapes[ch]; ok {
+		return val, nil
+	}
+
+	// 未知のエスケープはその文字自身
+	return int(ch), nil
+}
+
+func hexVal(ch rune) int {
+	switch {
+	case '0' <= ch && ch <= '9':
+		return int(ch - '0')
+	case 'a' <= ch && ch <= 'f':
+		return int(ch-'a') + 10
+	case 'A' <= ch && ch <= 'F':
+		return int(ch-'A') + 10
+	}
+
+	return -1
 }
 
 func skip(s []rune, start int) int {
@@ -406,26 +667,3 @@ func equal(a, b []rune) bool {
 	}
 	return true
 }
-
-func getLine(code []rune, idx int) (string, int, int) {
-	start := idx
-	end := idx
-
-	for start-1 >= 0 && code[start-1] != '\n' {
-		start--
-	}
-
-	for end+1 <= len(code)-1 && code[end+1] != '\n' {
-		end++
-	}
-
-	row := 0
-	for i := 0; i <= start; i++ {
-		if code[i] == '\n' {
-			row++
-		}
-	}
-
-	line := string(code[start : end+1])
-	return line, row, idx - start
-}
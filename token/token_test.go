@@ -1,6 +1,7 @@
 package token
 
 import (
+	"go9cc/diag"
 	"strings"
 	"testing"
 )
@@ -45,7 +46,10 @@ func TestTokenizerComment(t *testing.T) {
 70
 `
 	tzer := New(input)
-	cur := tzer.Tokenize()
+	cur, err := tzer.Tokenize()
+	if err != nil {
+		t.Fatalf("Tokenize: %s", err)
+	}
 
 	testToken(t, cur, NUM, 10, "10", nil)
 	cur = cur.Next
@@ -66,7 +70,10 @@ sizeof[]char"hello"
 `
 	s := strings.ReplaceAll(input, "\n", "")
 	tzer := New(s)
-	cur := tzer.Tokenize()
+	cur, err := tzer.Tokenize()
+	if err != nil {
+		t.Fatalf("Tokenize: %s", err)
+	}
 
 	testToken(t, cur, LPAREN, 0, "(", 0)
 	cur = cur.Next
@@ -159,6 +166,111 @@ sizeof[]char"hello"
 	testToken(t, cur, EOF, 0, "", 120)
 }
 
+func TestTokenizerPunct(t *testing.T) {
+	input := "a<<=b>>=c...d->e.f++ + ++g---h&&i&j||k|l^=m^n%=o%p~!q?r:s!=t+=u-=v*=w/=x&=y|=z<<1>>2"
+	want := []TokenKind{
+		IDENT, SHL_ASSIGN, IDENT, SHR_ASSIGN, IDENT, ELLIPSIS, IDENT, ARROW, IDENT, DOT, IDENT, INC, PLUS, INC,
+		IDENT, DEC, MINUS, IDENT, LAND, IDENT, AND, IDENT, LOR, IDENT, OR, IDENT, XOR_ASSIGN, IDENT, XOR,
+		IDENT, MOD_ASSIGN, IDENT, PERCENT, IDENT, TILDE, NOT, IDENT, QUESTION, IDENT, COLON, IDENT, NEQ,
+		IDENT, ADD_ASSIGN, IDENT, SUB_ASSIGN, IDENT, MUL_ASSIGN, IDENT, DIV_ASSIGN, IDENT, AND_ASSIGN,
+		IDENT, OR_ASSIGN, IDENT, SHL, NUM, SHR, NUM, EOF,
+	}
+
+	tzer := New(input)
+	cur, err := tzer.Tokenize()
+	if err != nil {
+		t.Fatalf("Tokenize: %s", err)
+	}
+
+	for i, kind := range want {
+		if cur == nil {
+			t.Fatalf("%d: too few tokens", i)
+		}
+
+		if cur.Kind != kind {
+			t.Fatalf("%d: wrong kind at %d: got=%s, want=%s", i, cur.Col, cur.Kind, kind)
+		}
+		cur = cur.Next
+	}
+}
+
+func TestTokenizerLiteral(t *testing.T) {
+	tests := []struct {
+		input string
+		kind  TokenKind
+		val   int
+		str   string
+	}{
+		{`'a'`, NUM, 97, `'a'`},
+		{`'\n'`, NUM, 10, `'\n'`},
+		{`'\0'`, NUM, 0, `'\0'`},
+		{`'\''`, NUM, 39, `'\''`},
+		{`'"'`, NUM, 34, `'"'`},
+		{`'\123'`, NUM, 83, `'\123'`},
+		{`'\x41'`, NUM, 65, `'\x41'`},
+		{`'\xff'`, NUM, -1, `'\xff'`},
+		{`0x1F`, NUM, 31, `0x1F`},
+		{`0XffUL`, NUM, 255, `0XffUL`},
+		{`0755`, NUM, 493, `0755`},
+		{`0`, NUM, 0, `0`},
+		{`0b1010`, NUM, 10, `0b1010`},
+		{`10u`, NUM, 10, `10u`},
+		{`10ll`, NUM, 10, `10ll`},
+		{`0xffffffffffffffff`, NUM, -1, `0xffffffffffffffff`},
+		{`" a\"b\\"`, STRING, 0, " a\"b\\"},
+		{`"\t\r\a\e\?"`, STRING, 0, "\t\r\a\x1b?"},
+		{`"\1010\x7e\0"`, STRING, 0, "A0~\x00"},
+	}
+
+	for i, tt := range tests {
+		tzer := New(tt.input)
+		cur, err := tzer.Tokenize()
+		if err != nil {
+			t.Fatalf("%d: Tokenize: %s", i, err)
+		}
+
+		testToken(t, cur, tt.kind, tt.val, tt.str, 0)
+		testToken(t, cur.Next, EOF, 0, "", nil)
+	}
+}
+
+func TestTokenizerError(t *testing.T) {
+	tests := []struct {
+		input string
+		msg   string
+		line  int
+		col   int
+	}{
+		{"a = 1;\nb = !@c;", "Unexpected char: @", 2, 6},
+		{"a = 1;\nb = $;", "Unexpected char: $", 2, 5},
+		{"a = \"abc;", "string not ended", 1, 5},
+		{"a = '';", "Empty character constant", 1, 5},
+		{"a = 'ab';", "char not ended", 1, 5},
+		{"a = 089;", "Invalid digit 8 in octal constant", 1, 6},
+		{"a = 0x;", "Invalid integer constant: no digits", 1, 5},
+		{"a = 12abc;", "Invalid suffix abc on integer constant", 1, 7},
+		{"a = 0x10000000000000000;", "Integer literal 0x10000000000000000 is too large to be represented in any integer type", 1, 5},
+		{"a = \"\\xg\";", "\\x used with no following hex digits", 1, 8},
+	}
+
+	for i, tt := range tests {
+		tzer := New(tt.input)
+		_, err := tzer.Tokenize()
+		d, ok := err.(*diag.Diagnostic)
+		if !ok {
+			t.Fatalf("%d: *diag.Diagnostic expected, but got=%T", i, err)
+		}
+
+		if d.Message != tt.msg {
+			t.Errorf("%d: wrong msg: got=%s, want=%s", i, d.Message, tt.msg)
+		}
+
+		if d.Pos.Line() != tt.line || d.Pos.Column() != tt.col {
+			t.Errorf("%d: wrong position: got=%d:%d, want=%d:%d", i, d.Pos.Line(), d.Pos.Column(), tt.line, tt.col)
+		}
+	}
+}
+
 func testToken(t *testing.T, token *Token, kind TokenKind, val int, str string, col interface{}) {
 	if token.Kind != kind {
 		t.Fatalf("Wrong TokenKind: %s != %s", token.Kind, kind)
@@ -179,34 +291,22 @@ func testToken(t *testing.T, token *Token, kind TokenKind, val int, str string,
 
 }
 
-func TestGetLine(t *testing.T) {
+func TestStripComments(t *testing.T) {
 	tests := []struct {
-		code  string
-		idx   int
-		wants string
-		wantr int
-		wantc int
+		code string
+		want string
 	}{
-		{"aaa\nbbb\nccc", 0, "aaa", 0, 0},
-		{"aaa\nbbb\nccc", 1, "aaa", 0, 1},
-		{"aaa\nbbb\nccc", 4, "bbb", 1, 0},
-		{"aaa\nbbb\nccc", 6, "bbb", 1, 2},
-		{"aaa\nbbb\nccc", 8, "ccc", 2, 0},
-		{"aaa\nbbb\nccc", 10, "ccc", 2, 2},
+		{"int a; // comment\nint b;", "int a;  \nint b;"},
+		{"a /* x\ny */ b", "a  \n b"},
+		{"\"// not a comment\" /* c */", "\"// not a comment\"  "},
+		{"'/' /* unterminated", "'/'  "},
+		{"a // EOF", "a  "},
 	}
 
 	for i, tt := range tests {
-		line, row, col := getLine([]rune(tt.code), tt.idx)
-		if line != tt.wants {
-			t.Errorf("Case%d line: got=%s, want=%s\n", i, line, tt.wants)
-		}
-
-		if row != tt.wantr {
-			t.Errorf("Case%d  row: got=%d, want=%d\n", i, row, tt.wantr)
-		}
-
-		if col != tt.wantc {
-			t.Errorf("Case%d col: got=%d, want=%d\n", i, col, tt.wantc)
+		got := StripComments(tt.code)
+		if got != tt.want {
+			t.Errorf("%d: got=%q, want=%q", i, got, tt.want)
 		}
 	}
 }
@@ -11,8 +11,15 @@ const (
 )
 
 var (
-	int_  = &Int{}
-	char_ = &Char{}
+	int_    = &Int{}
+	uint_   = &Int{Unsigned: true}
+	long_   = &Long{}
+	ulong_  = &Long{Unsigned: true}
+	char_   = &Char{}
+	uchar_  = &Char{Unsigned: true}
+	short_  = &Short{}
+	ushort_ = &Short{Unsigned: true}
+	void_   = &Void{}
 )
 
 type Type interface {
@@ -22,12 +29,62 @@ type Type interface {
 	CanAssign(right Type) bool
 	CanAdd(right Type) bool
 	CanMul(right Type) bool
+	CanMod(right Type) bool
+	CanBitwise(right Type) bool // & | ^
+	CanShift(right Type) bool
 }
 
+// Void has no value. It is the result of a function returning nothing
+// or the base of a generic pointer.
+type Void struct {
+}
+
+func (t *Void) String() string {
+	return "void"
+}
+
+// Size is 1 like GNU C, so that void* moves by bytes.
+func (t *Void) Size() int {
+	return 1
+}
+
+func (t *Void) StackSize() int {
+	return 1
+}
+
+func (t *Void) CanAssign(right Type) bool {
+	return false
+}
+
+func (t *Void) CanAdd(right Type) bool {
+	return false
+}
+
+func (t *Void) CanMul(right Type) bool {
+	return false
+}
+
+func (t *Void) CanMod(right Type) bool {
+	return false
+}
+
+func (t *Void) CanBitwise(right Type) bool {
+	return false
+}
+
+func (t *Void) CanShift(right Type) bool {
+	return false
+}
+
+// Char is signed unless Unsigned, like char on x86-64.
 type Char struct {
+	Unsigned bool
 }
 
 func (t *Char) String() string {
+	if t.Unsigned {
+		return "unsigned char"
+	}
 	return "char"
 }
 
@@ -40,21 +97,80 @@ func (t *Char) StackSize() int {
 }
 
 func (t *Char) CanAssign(right Type) bool {
-	return right == int_ || right == char_
+	return IsInteger(right)
 }
 
 func (t *Char) CanAdd(right Type) bool {
-	return right == int_ || right == char_
+	return IsInteger(right)
 }
 
 func (t *Char) CanMul(right Type) bool {
-	return right == int_ || right == char_
+	return IsInteger(right)
+}
+
+func (t *Char) CanMod(right Type) bool {
+	return IsInteger(right)
+}
+
+func (t *Char) CanBitwise(right Type) bool {
+	return IsInteger(right)
+}
+
+func (t *Char) CanShift(right Type) bool {
+	return IsInteger(right)
+}
+
+type Short struct {
+	Unsigned bool
+}
+
+func (t *Short) String() string {
+	if t.Unsigned {
+		return "unsigned short"
+	}
+	return "short"
+}
+
+func (t *Short) Size() int {
+	return 2
+}
+
+func (t *Short) StackSize() int {
+	return 2
+}
+
+func (t *Short) CanAssign(right Type) bool {
+	return IsInteger(right)
+}
+
+func (t *Short) CanAdd(right Type) bool {
+	return IsInteger(right)
+}
+
+func (t *Short) CanMul(right Type) bool {
+	return IsInteger(right)
+}
+
+func (t *Short) CanMod(right Type) bool {
+	return IsInteger(right)
+}
+
+func (t *Short) CanBitwise(right Type) bool {
+	return IsInteger(right)
+}
+
+func (t *Short) CanShift(right Type) bool {
+	return IsInteger(right)
 }
 
 type Int struct {
+	Unsigned bool
 }
 
 func (t *Int) String() string {
+	if t.Unsigned {
+		return "unsigned int"
+	}
 	return "int"
 }
 
@@ -67,15 +183,71 @@ func (t *Int) StackSize() int {
 }
 
 func (t *Int) CanAssign(right Type) bool {
-	return right == int_ || right == char_
+	return IsInteger(right)
 }
 
 func (t *Int) CanAdd(right Type) bool {
-	return right == int_ || right == char_
+	return IsInteger(right)
 }
 
 func (t *Int) CanMul(right Type) bool {
-	return right == int_ || right == char_
+	return IsInteger(right)
+}
+
+func (t *Int) CanMod(right Type) bool {
+	return IsInteger(right)
+}
+
+func (t *Int) CanBitwise(right Type) bool {
+	return IsInteger(right)
+}
+
+func (t *Int) CanShift(right Type) bool {
+	return IsInteger(right)
+}
+
+// Long is long and long long, which are both 64 bits.
+type Long struct {
+	Unsigned bool
+}
+
+func (t *Long) String() string {
+	if t.Unsigned {
+		return "unsigned long"
+	}
+	return "long"
+}
+
+func (t *Long) Size() int {
+	return 8
+}
+
+func (t *Long) StackSize() int {
+	return 8
+}
+
+func (t *Long) CanAssign(right Type) bool {
+	return IsInteger(right)
+}
+
+func (t *Long) CanAdd(right Type) bool {
+	return IsInteger(right)
+}
+
+func (t *Long) CanMul(right Type) bool {
+	return IsInteger(right)
+}
+
+func (t *Long) CanMod(right Type) bool {
+	return IsInteger(right)
+}
+
+func (t *Long) CanBitwise(right Type) bool {
+	return IsInteger(right)
+}
+
+func (t *Long) CanShift(right Type) bool {
+	return IsInteger(right)
 }
 
 type IntPointer struct {
@@ -83,18 +255,7 @@ type IntPointer struct {
 }
 
 func (t *IntPointer) String() string {
-	s := "*"
-	base := t.Base
-	for {
-		switch ty := base.(type) {
-		case *Int:
-			s = "int" + s
-			return s
-		case *IntPointer:
-			s = "*" + s
-			base = ty.Base
-		}
-	}
+	return t.Base.String() + "*"
 }
 
 func (t *IntPointer) Size() int {
@@ -105,24 +266,42 @@ func (t *IntPointer) StackSize() int {
 	return 8
 }
 
+// CanAssign accepts a pointer or an array of the same base. void*
+// converts to and from any other pointer.
 func (t *IntPointer) CanAssign(right Type) bool {
-	_, ok := right.(*IntPointer)
-	if ok {
-		return true
+	var base Type
+	switch right := right.(type) {
+	case *IntPointer:
+		base = right.Base
+	case *Array:
+		base = right.Base
+	default:
+		return false
 	}
 
-	_, ok = right.(*Array)
-	return ok
+	return IsVoid(t.Base) || IsVoid(base) || Same(t.Base, base)
 }
 
 func (t *IntPointer) CanAdd(right Type) bool {
-	return right == int_ || right == char_
+	return IsInteger(right)
 }
 
 func (t *IntPointer) CanMul(right Type) bool {
 	return false
 }
 
+func (t *IntPointer) CanMod(right Type) bool {
+	return false
+}
+
+func (t *IntPointer) CanBitwise(right Type) bool {
+	return false
+}
+
+func (t *IntPointer) CanShift(right Type) bool {
+	return false
+}
+
 type Array struct {
 	Base   Type
 	Length int
@@ -143,6 +322,9 @@ func (t *Array) StackSize() int {
 	return t.Base.StackSize() * t.Length
 }
 
+// CanAssign accepts an array of the same length and base. A string
+// literal, which is a char array, initializes an array of any character
+// type.
 func (t *Array) CanAssign(right Type) bool {
 	arr, ok := right.(*Array)
 	if !ok {
@@ -153,31 +335,345 @@ func (t *Array) CanAssign(right Type) bool {
 		return false
 	}
 
-	if arr.Base != t.Base {
-		return false
+	if IsChar(t.Base) && IsChar(arr.Base) {
+		return true
 	}
 
-	return true
+	return Same(arr.Base, t.Base)
 }
 
 func (t *Array) CanAdd(right Type) bool {
-	return right == int_ || right == char_
+	return IsInteger(right)
 }
 
 func (t *Array) CanMul(right Type) bool {
 	return false
 }
 
+func (t *Array) CanMod(right Type) bool {
+	return false
+}
+
+func (t *Array) CanBitwise(right Type) bool {
+	return false
+}
+
+func (t *Array) CanShift(right Type) bool {
+	return false
+}
+
+// Member is a member of a struct or a union.
+type Member struct {
+	Name   string
+	Type   Type
+	Offset int
+}
+
+// Struct is a struct, or a union if IsUnion. It is incomplete until
+// SetMembers lays out its members. Struct types are compared by
+// identity: two definitions are different types even with the same tag.
+type Struct struct {
+	Tag      string // "" if anonymous
+	IsUnion  bool
+	Members  []*Member
+	size     int
+	align    int
+	complete bool
+}
+
+func (t *Struct) String() string {
+	tag := t.Tag
+	if tag == "" {
+		tag = "<anonymous>"
+	}
+	return t.Kind() + " " + tag
+}
+
+// Kind returns "struct" or "union".
+func (t *Struct) Kind() string {
+	if t.IsUnion {
+		return "union"
+	}
+	return "struct"
+}
+
+func (t *Struct) Size() int {
+	return t.size
+}
+
+func (t *Struct) StackSize() int {
+	return t.size
+}
+
+func (t *Struct) CanAssign(right Type) bool {
+	return t == right
+}
+
+func (t *Struct) CanAdd(right Type) bool {
+	return false
+}
+
+func (t *Struct) CanMul(right Type) bool {
+	return false
+}
+
+func (t *Struct) CanMod(right Type) bool {
+	return false
+}
+
+func (t *Struct) CanBitwise(right Type) bool {
+	return false
+}
+
+func (t *Struct) CanShift(right Type) bool {
+	return false
+}
+
+// SetMembers completes t with members laid out by the System V ABI:
+// each member is aligned to its own alignment, and the size is rounded
+// up to the strictest one. All members of a union are at offset 0, and
+// its size is that of the largest member.
+func (t *Struct) SetMembers(members []*Member) {
+	size, align := 0, 1
+	for _, m := range members {
+		a := Align(m.Type)
+		if a > align {
+			align = a
+		}
+
+		if t.IsUnion {
+			m.Offset = 0
+			if m.Type.StackSize() > size {
+				size = m.Type.StackSize()
+			}
+			continue
+		}
+
+		m.Offset = alignTo(size, a)
+		size = m.Offset + m.Type.StackSize()
+	}
+
+	t.Members = members
+	t.size = alignTo(size, align)
+	t.align = align
+	t.complete = true
+}
+
+// Member returns the member named name, or nil if there is none.
+func (t *Struct) Member(name string) *Member {
+	for _, m := range t.Members {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// IsComplete reports whether the members of t are defined.
+func (t *Struct) IsComplete() bool {
+	return t.complete
+}
+
+// Align returns the alignment of t in bytes.
+func Align(t Type) int {
+	switch t := t.(type) {
+	case *Struct:
+		return t.align
+	case *Array:
+		return Align(t.Base)
+	case *Void:
+		return 1
+	}
+	return t.Size()
+}
+
+// IsComplete reports whether the size of t is known. Only structs,
+// unions and arrays of them can be incomplete.
+func IsComplete(t Type) bool {
+	switch t := t.(type) {
+	case *Struct:
+		return t.complete
+	case *Array:
+		return IsComplete(t.Base)
+	}
+	return true
+}
+
+func alignTo(n, align int) int {
+	return (n + align - 1) / align * align
+}
+
+// IsInteger reports whether t is an integer type.
+func IsInteger(t Type) bool {
+	switch t.(type) {
+	case *Char, *Short, *Int, *Long:
+		return true
+	}
+	return false
+}
+
+// IsChar reports whether t is a character type: char, signed char or
+// unsigned char.
+func IsChar(t Type) bool {
+	_, ok := t.(*Char)
+	return ok
+}
+
+// IsVoid reports whether t is void.
+func IsVoid(t Type) bool {
+	_, ok := t.(*Void)
+	return ok
+}
+
+// Same reports whether a and b are the same type. Structs and unions are
+// the same only if they are the same declaration, even if they print
+// the same.
+func Same(a, b Type) bool {
+	switch a := a.(type) {
+	case *Void:
+		_, ok := b.(*Void)
+		return ok
+	case *Char:
+		b, ok := b.(*Char)
+		return ok && a.Unsigned == b.Unsigned
+	case *Short:
+		b, ok := b.(*Short)
+		return ok && a.Unsigned == b.Unsigned
+	case *Int:
+		b, ok := b.(*Int)
+		return ok && a.Unsigned == b.Unsigned
+	case *Long:
+		b, ok := b.(*Long)
+		return ok && a.Unsigned == b.Unsigned
+	case *IntPointer:
+		b, ok := b.(*IntPointer)
+		return ok && Same(a.Base, b.Base)
+	case *Array:
+		b, ok := b.(*Array)
+		return ok && a.Length == b.Length && Same(a.Base, b.Base)
+	case *Struct:
+		b, ok := b.(*Struct)
+		return ok && a == b
+	}
+	return false
+}
+
+// IsUnsigned reports whether t is an unsigned integer type.
+func IsUnsigned(t Type) bool {
+	switch t := t.(type) {
+	case *Char:
+		return t.Unsigned
+	case *Short:
+		return t.Unsigned
+	case *Int:
+		return t.Unsigned
+	case *Long:
+		return t.Unsigned
+	}
+	return false
+}
+
+// Common returns the type of an arithmetic operation on a and b after
+// the usual arithmetic conversions (C11 6.3.1.8) on LP64: char and
+// short are promoted to int, and the wider or unsigned type wins. long
+// can hold every unsigned int, so long and unsigned int make long.
+func Common(a, b Type) Type {
+	a, b = Promote(a), Promote(b)
+	_, aLong := a.(*Long)
+	_, bLong := b.(*Long)
+	switch {
+	case aLong && bLong:
+		if IsUnsigned(a) || IsUnsigned(b) {
+			return ulong_
+		}
+		return long_
+	case aLong:
+		return a
+	case bLong:
+		return b
+	case IsUnsigned(a) || IsUnsigned(b):
+		return uint_
+	}
+	return int_
+}
+
+// Convert returns val converted to the integer type t. It wraps around
+// like the machine does: Convert(256, char) is 0 and Convert(-1,
+// unsigned int) is 4294967295.
+func Convert(val int, t Type) int {
+	if !IsInteger(t) {
+		return val
+	}
+
+	unsigned := IsUnsigned(t)
+	switch t.Size() {
+	case 1:
+		if unsigned {
+			return int(uint8(val))
+		}
+		return int(int8(val))
+	case 2:
+		if unsigned {
+			return int(uint16(val))
+		}
+		return int(int16(val))
+	case 4:
+		if unsigned {
+			return int(uint32(val))
+		}
+		return int(int32(val))
+	}
+	return val
+}
+
+// Promote returns the type of an integer operand after the integer
+// promotions: char and short become int. Other types are returned as is.
+func Promote(t Type) Type {
+	switch t.(type) {
+	case *Char, *Short:
+		return int_
+	}
+	return t
+}
+
 /* Factory */
 
 func GetInt() Type {
 	return int_
 }
 
+func GetUInt() Type {
+	return uint_
+}
+
+func GetLong() Type {
+	return long_
+}
+
+func GetULong() Type {
+	return ulong_
+}
+
 func GetChar() Type {
 	return char_
 }
 
+func GetUChar() Type {
+	return uchar_
+}
+
+func GetShort() Type {
+	return short_
+}
+
+func GetUShort() Type {
+	return ushort_
+}
+
+func GetVoid() Type {
+	return void_
+}
+
 func PointerTo(base Type) Type {
 	return &IntPointer{Base: base}
 }
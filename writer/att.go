@@ -54,12 +54,48 @@ func (g *ATT) Mul(rad1, rad2 string) {
 	io.WriteString(g.buf, s)
 }
 
+func (g *ATT) And(rad1, rad2 string) {
+	s := fmt.Sprintf("  and %s, %%%s\n", prefixed(rad1), rad2)
+	io.WriteString(g.buf, s)
+}
+
+func (g *ATT) Or(rad1, rad2 string) {
+	s := fmt.Sprintf("  or %s, %%%s\n", prefixed(rad1), rad2)
+	io.WriteString(g.buf, s)
+}
+
+func (g *ATT) Xor(rad1, rad2 string) {
+	s := fmt.Sprintf("  xor %s, %%%s\n", prefixed(rad1), rad2)
+	io.WriteString(g.buf, s)
+}
+
+func (g *ATT) Sal(rad string) {
+	s := fmt.Sprintf("  sal %%cl, %%%s\n", rad)
+	io.WriteString(g.buf, s)
+}
+
+func (g *ATT) Sar(rad string) {
+	s := fmt.Sprintf("  sar %%cl, %%%s\n", rad)
+	io.WriteString(g.buf, s)
+}
+
+func (g *ATT) Shr(rad string) {
+	s := fmt.Sprintf("  shr %%cl, %%%s\n", rad)
+	io.WriteString(g.buf, s)
+}
+
 func (g *ATT) Div(rad string) {
 	io.WriteString(g.buf, "  cqo\n")       // RAXのコードを伸ばしてRDX/RAXにセットする
 	s := fmt.Sprintf("  idiv %%%s\n", rad) // RDX/RAXを128bitとみなして`rad`のレジスタの値で符号付除算
 	io.WriteString(g.buf, s)
 }
 
+func (g *ATT) Udiv(rad string) {
+	io.WriteString(g.buf, "  xor %edx, %edx\n") // 符号なしなのでRDXは0
+	s := fmt.Sprintf("  div %%%s\n", rad)       // RDX/RAXを`rad`のレジスタの値で符号無し除算
+	io.WriteString(g.buf, s)
+}
+
 func (g *ATT) Push(val string) {
 	s := fmt.Sprintf("  push %%%s\n", val)
 	io.WriteString(g.buf, s)
@@ -90,6 +126,36 @@ func (g *ATT) Setle(rad1 string) {
 	io.WriteString(g.buf, s)
 }
 
+func (g *ATT) Setg(rad1 string) {
+	s := fmt.Sprintf("  setg %%%s\n", rad1)
+	io.WriteString(g.buf, s)
+}
+
+func (g *ATT) Setge(rad1 string) {
+	s := fmt.Sprintf("  setge %%%s\n", rad1)
+	io.WriteString(g.buf, s)
+}
+
+func (g *ATT) Setb(rad1 string) {
+	s := fmt.Sprintf("  setb %%%s\n", rad1)
+	io.WriteString(g.buf, s)
+}
+
+func (g *ATT) Setbe(rad1 string) {
+	s := fmt.Sprintf("  setbe %%%s\n", rad1)
+	io.WriteString(g.buf, s)
+}
+
+func (g *ATT) Seta(rad1 string) {
+	s := fmt.Sprintf("  seta %%%s\n", rad1)
+	io.WriteString(g.buf, s)
+}
+
+func (g *ATT) Setae(rad1 string) {
+	s := fmt.Sprintf("  setae %%%s\n", rad1)
+	io.WriteString(g.buf, s)
+}
+
 func (g *ATT) Je(label string) {
 	s := fmt.Sprintf("  je %s\n", label)
 	io.WriteString(g.buf, s)
@@ -100,11 +166,21 @@ func (g *ATT) Jne(label string) {
 	io.WriteString(g.buf, s)
 }
 
+func (g *ATT) Ja(label string) {
+	s := fmt.Sprintf("  ja %s\n", label)
+	io.WriteString(g.buf, s)
+}
+
 func (g *ATT) Jmp(label string) {
 	s := fmt.Sprintf("  jmp %s\n", label)
 	io.WriteString(g.buf, s)
 }
 
+func (g *ATT) JmpIndirect(rad string) {
+	s := fmt.Sprintf("  jmp *%%%s\n", rad)
+	io.WriteString(g.buf, s)
+}
+
 func (g *ATT) Call(label string) {
 	s := fmt.Sprintf("  call %s\n", label)
 	io.WriteString(g.buf, s)
@@ -125,6 +201,16 @@ func (g *ATT) Movsx(rad1, rad2 string) {
 	io.WriteString(g.buf, s)
 }
 
+func (g *ATT) Movzx(rad1, rad2 string) {
+	s := fmt.Sprintf("  movzx %s, %%%s\n", prefixed(rad1), rad2)
+	io.WriteString(g.buf, s)
+}
+
+func (g *ATT) Movsxd(rad1, rad2 string) {
+	s := fmt.Sprintf("  movslq %s, %%%s\n", prefixed(rad1), rad2)
+	io.WriteString(g.buf, s)
+}
+
 func (g *ATT) Lea(offset, rad1, rad2 string) {
 	s := fmt.Sprintf("  lea %s(%%%s), %%%s\n", offset, rad1, rad2)
 	io.WriteString(g.buf, s)
@@ -135,6 +221,16 @@ func (g *ATT) Neg(rad1 string) {
 	io.WriteString(g.buf, s)
 }
 
+func (g *ATT) Not(rad1 string) {
+	s := fmt.Sprintf("  not %%%s\n", rad1)
+	io.WriteString(g.buf, s)
+}
+
+// RepStosb stores AL to RCX bytes from the address in RDI.
+func (g *ATT) RepStosb() {
+	io.WriteString(g.buf, "  rep stosb\n")
+}
+
 func (g *ATT) Ret() {
 	io.WriteString(g.buf, "  ret\n")
 }
@@ -149,7 +245,11 @@ func (g *ATT) Globl(label string) {
 }
 
 func (g *ATT) String(value string) {
-	g.Text(fmt.Sprintf(".string \"%s\"", value))
+	g.Text(fmt.Sprintf(".string %s", quote(value)))
+}
+
+func (g *ATT) Ascii(value string) {
+	g.Text(fmt.Sprintf(".ascii %s", quote(value)))
 }
 
 func (g *ATT) Size(value int) {
@@ -160,6 +260,10 @@ func (g *ATT) Data() {
 	g.Text(".data")
 }
 
+func (g *ATT) Rodata() {
+	g.Text(".section .rodata")
+}
+
 func (g *ATT) Text(text string) {
 	s := fmt.Sprintf("  %s\n", text)
 	io.WriteString(g.buf, s)
@@ -186,3 +290,24 @@ func prefixed(src string) string {
 
 	return fmt.Sprintf("%%%s", src)
 }
+
+// quote escapes the bytes of value for .string and .ascii. Bytes other
+// than printable ASCII are written in octal.
+func quote(value string) string {
+	var out bytes.Buffer
+	out.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '"' || c == '\\':
+			out.WriteByte('\\')
+			out.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&out, "\\%03o", c)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
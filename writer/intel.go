@@ -14,6 +14,13 @@ type Writer interface {
 	Sub(string, string)
 	Mul(string, string)
 	Div(string)
+	Udiv(string)
+	And(string, string)
+	Or(string, string)
+	Xor(string, string)
+	Sal(string) // shift left by CL
+	Sar(string) // arithmetic shift right by CL
+	Shr(string) // logical shift right by CL
 	Lea(offset, rad1, rad2 string)
 	Push(string)
 	Pop(string)
@@ -21,20 +28,34 @@ type Writer interface {
 	Setne(string)
 	Setl(rad1 string)
 	Setle(rad1 string)
+	Setg(rad1 string)
+	Setge(rad1 string)
+	Setb(rad1 string)
+	Setbe(rad1 string)
+	Seta(rad1 string)
+	Setae(rad1 string)
 	Je(label string)
 	Jne(label string)
+	Ja(label string)
 	Jmp(label string)
+	JmpIndirect(rad string)
 	Call(label string)
 	Cmp(rad1, rad2 string)
 	Movzb(rad1, rad2 string)
 	Movsx(rad1, rad2 string)
+	Movzx(rad1, rad2 string)
+	Movsxd(rad1, rad2 string)
 	Neg(rad1 string)
+	Not(rad1 string)
+	RepStosb()
 	Ret()
 	Globl(label string)
 	Size(size int)
 	Data()
+	Rodata()
 	Label(name string)
 	String(value string)
+	Ascii(value string)
 	Text(text string)
 	Address(name string) string
 	Index(base, unit string, size int) string
@@ -80,12 +101,48 @@ func (g *Intel) Mul(rad1, rad2 string) {
 	io.WriteString(g.buf, s)
 }
 
+func (g *Intel) And(rad1, rad2 string) {
+	s := fmt.Sprintf("  and %s, %s\n", rad2, rad1)
+	io.WriteString(g.buf, s)
+}
+
+func (g *Intel) Or(rad1, rad2 string) {
+	s := fmt.Sprintf("  or %s, %s\n", rad2, rad1)
+	io.WriteString(g.buf, s)
+}
+
+func (g *Intel) Xor(rad1, rad2 string) {
+	s := fmt.Sprintf("  xor %s, %s\n", rad2, rad1)
+	io.WriteString(g.buf, s)
+}
+
+func (g *Intel) Sal(rad string) {
+	s := fmt.Sprintf("  sal %s, cl\n", rad)
+	io.WriteString(g.buf, s)
+}
+
+func (g *Intel) Sar(rad string) {
+	s := fmt.Sprintf("  sar %s, cl\n", rad)
+	io.WriteString(g.buf, s)
+}
+
+func (g *Intel) Shr(rad string) {
+	s := fmt.Sprintf("  shr %s, cl\n", rad)
+	io.WriteString(g.buf, s)
+}
+
 func (g *Intel) Div(rad string) {
 	io.WriteString(g.buf, "  cqo\n")     // RAXのコードを伸ばしてRDX/RAXにセットする
 	s := fmt.Sprintf("  idiv %s\n", rad) // RDX/RAXを128bitとみなして`rad`のレジスタの値で符号付除算
 	io.WriteString(g.buf, s)
 }
 
+func (g *Intel) Udiv(rad string) {
+	io.WriteString(g.buf, "  xor edx, edx\n") // 符号なしなのでRDXは0
+	s := fmt.Sprintf("  div %s\n", rad)       // RDX/RAXを`rad`のレジスタの値で符号無し除算
+	io.WriteString(g.buf, s)
+}
+
 func (g *Intel) Push(val string) {
 	s := fmt.Sprintf("  push %s\n", val)
 	io.WriteString(g.buf, s)
@@ -116,6 +173,36 @@ func (g *Intel) Setle(rad1 string) {
 	io.WriteString(g.buf, s)
 }
 
+func (g *Intel) Setg(rad1 string) {
+	s := fmt.Sprintf("  setg %s\n", rad1)
+	io.WriteString(g.buf, s)
+}
+
+func (g *Intel) Setge(rad1 string) {
+	s := fmt.Sprintf("  setge %s\n", rad1)
+	io.WriteString(g.buf, s)
+}
+
+func (g *Intel) Setb(rad1 string) {
+	s := fmt.Sprintf("  setb %s\n", rad1)
+	io.WriteString(g.buf, s)
+}
+
+func (g *Intel) Setbe(rad1 string) {
+	s := fmt.Sprintf("  setbe %s\n", rad1)
+	io.WriteString(g.buf, s)
+}
+
+func (g *Intel) Seta(rad1 string) {
+	s := fmt.Sprintf("  seta %s\n", rad1)
+	io.WriteString(g.buf, s)
+}
+
+func (g *Intel) Setae(rad1 string) {
+	s := fmt.Sprintf("  setae %s\n", rad1)
+	io.WriteString(g.buf, s)
+}
+
 func (g *Intel) Je(label string) {
 	s := fmt.Sprintf("  je %s\n", label)
 	io.WriteString(g.buf, s)
@@ -126,11 +213,21 @@ func (g *Intel) Jne(label string) {
 	io.WriteString(g.buf, s)
 }
 
+func (g *Intel) Ja(label string) {
+	s := fmt.Sprintf("  ja %s\n", label)
+	io.WriteString(g.buf, s)
+}
+
 func (g *Intel) Jmp(label string) {
 	s := fmt.Sprintf("  jmp %s\n", label)
 	io.WriteString(g.buf, s)
 }
 
+func (g *Intel) JmpIndirect(rad string) {
+	s := fmt.Sprintf("  jmp %s\n", rad)
+	io.WriteString(g.buf, s)
+}
+
 func (g *Intel) Call(label string) {
 	s := fmt.Sprintf("  call %s\n", label)
 	io.WriteString(g.buf, s)
@@ -151,6 +248,16 @@ func (g *Intel) Movsx(rad1, rad2 string) {
 	io.WriteString(g.buf, s)
 }
 
+func (g *Intel) Movzx(rad1, rad2 string) {
+	s := fmt.Sprintf("  movzx %s, %s\n", rad2, rad1)
+	io.WriteString(g.buf, s)
+}
+
+func (g *Intel) Movsxd(rad1, rad2 string) {
+	s := fmt.Sprintf("  movsxd %s, %s\n", rad2, rad1)
+	io.WriteString(g.buf, s)
+}
+
 func (g *Intel) Lea(offset, rad1, rad2 string) {
 	s := fmt.Sprintf("  lea %s, %s[%s]\n", rad2, offset, rad1)
 	io.WriteString(g.buf, s)
@@ -161,6 +268,16 @@ func (g *Intel) Neg(rad1 string) {
 	io.WriteString(g.buf, s)
 }
 
+func (g *Intel) Not(rad1 string) {
+	s := fmt.Sprintf("  not %s\n", rad1)
+	io.WriteString(g.buf, s)
+}
+
+// RepStosb stores AL to RCX bytes from the address in RDI.
+func (g *Intel) RepStosb() {
+	io.WriteString(g.buf, "  rep stosb\n")
+}
+
 func (g *Intel) Ret() {
 	io.WriteString(g.buf, "  ret\n")
 }
@@ -175,7 +292,11 @@ func (g *Intel) Globl(label string) {
 }
 
 func (g *Intel) String(value string) {
-	g.Text(fmt.Sprintf(".string \"%s\"", value))
+	g.Text(fmt.Sprintf(".string %s", quote(value)))
+}
+
+func (g *Intel) Ascii(value string) {
+	g.Text(fmt.Sprintf(".ascii %s", quote(value)))
 }
 
 func (g *Intel) Size(value int) {
@@ -186,6 +307,10 @@ func (g *Intel) Data() {
 	g.Text(".data")
 }
 
+func (g *Intel) Rodata() {
+	g.Text(".section .rodata")
+}
+
 func (g *Intel) Text(text string) {
 	s := fmt.Sprintf("  %s\n", text)
 	io.WriteString(g.buf, s)